@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Audiobookshelf podcast metadata.
+type absPodcast struct {
+	Title       string   `json:"title"`
+	Author      string   `json:"author,omitempty"`
+	Description string   `json:"description,omitempty"`
+	ReleaseDate string   `json:"releaseDate,omitempty"`
+	Genres      []string `json:"genres"`
+	Tags        []string `json:"tags"`
+	FeedURL     string   `json:"feedURL"`
+	ImageURL    string   `json:"imageURL,omitempty"`
+	Explicit    bool     `json:"explicit"`
+	Language    string   `json:"language,omitempty"`
+	Type        string   `json:"type"`
+}
+
+// Profile writing metadata.json recognized by Audiobookshelf.
+// Audiobookshelf expects a folder per podcast with episodes and cover.* inside, that is the layout of the download
+// directories, so point the podcasts library to ~/Music/Podcast.
+type absProfile struct{}
+
+func (p absProfile) Export(dl *Glsdl) error {
+	meta := absPodcast{
+		Title:   dl.conf.Name,
+		Author:  dl.itemAlbumArtist(),
+		Genres:  make([]string, 0),
+		Tags:    make([]string, 0),
+		FeedURL: dl.conf.URL,
+		Type:    "episodic",
+	}
+	if feed := dl.feed; feed != nil {
+		meta.Title = firstOf(dl.expandTag(dl.conf.Tags.Album, nil), feed.Title, dl.conf.Name)
+		meta.Description = feed.Description
+		meta.Language = feed.Language
+		if feed.PublishedParsed != nil {
+			meta.ReleaseDate = feed.PublishedParsed.Format("2006-01-02T15:04:05Z07:00")
+		}
+		if feed.Image != nil {
+			meta.ImageURL = feed.Image.URL
+		}
+		if ext := feed.ITunesExt; ext != nil {
+			for _, c := range ext.Categories {
+				meta.Genres = append(meta.Genres, c.Text)
+			}
+			meta.Explicit = ext.Explicit == "yes" || ext.Explicit == "true"
+			if len(ext.Type) > 0 {
+				meta.Type = ext.Type
+			}
+			for _, kw := range strings.Split(ext.Keywords, ",") {
+				if kw = strings.TrimSpace(kw); len(kw) > 0 {
+					meta.Tags = append(meta.Tags, kw)
+				}
+			}
+		}
+	}
+	if len(meta.Genres) == 0 {
+		meta.Genres = append(meta.Genres, dl.itemGenre(&gofeed.Item{}))
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dl.downloadDir+ps+"metadata.json", data, 0644)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Adjusts the number of active download workers between 1 and -t threads: the limit is halved when the
+// hosts time out or throttle the downloads, and grows by one after the round of the downloads which
+// throughput isn't worse than the previous one. The growth is taken back if the throughput drops.
+type adaptiveLimit struct {
+	mux    sync.Mutex
+	cond   *sync.Cond
+	max    int
+	limit  int
+	active int
+	// Downloads of the current round, its bytes and start time.
+	done  int
+	bytes int64
+	start time.Time
+	// Throughput of the previous round and whether the limit grew after it.
+	rate  float64
+	grown bool
+}
+
+// Make the limit starting from the half of the threads.
+func newAdaptiveLimit(threads int) *adaptiveLimit {
+	a := &adaptiveLimit{max: max(threads, 1), limit: max(threads/2, 1)}
+	a.cond = sync.NewCond(&a.mux)
+	return a
+}
+
+// Take the active worker, blocks while the limit is reached. Nil limit has no effect.
+func (a *adaptiveLimit) acquire() (release func()) {
+	if a == nil {
+		return func() {}
+	}
+	a.mux.Lock()
+	for a.active >= a.limit {
+		a.cond.Wait()
+	}
+	a.active++
+	a.mux.Unlock()
+	return func() {
+		a.mux.Lock()
+		a.active--
+		a.mux.Unlock()
+		a.cond.Broadcast()
+	}
+}
+
+// Account the finished download of the size and adjust the limit.
+func (a *adaptiveLimit) observe(err error, size int64, elapsed time.Duration) {
+	if a == nil {
+		return
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if err != nil {
+		if congested(err) {
+			a.limit = max(a.limit/2, 1)
+			a.reset(0, false)
+		}
+		return
+	}
+	if a.done == 0 {
+		a.start = time.Now().Add(-elapsed)
+	}
+	a.done++
+	a.bytes += size
+	if a.done < a.limit {
+		return
+	}
+	rate := float64(a.bytes) / max(time.Since(a.start).Seconds(), 0.001)
+	switch {
+	case a.grown && rate < a.rate*0.9:
+		// More workers made it slower.
+		a.limit = max(a.limit-1, 1)
+		a.reset(rate, false)
+	case a.limit < a.max:
+		a.limit++
+		a.reset(rate, true)
+		a.cond.Broadcast()
+	default:
+		a.reset(rate, false)
+	}
+}
+
+// Start the new round after the previous one had the throughput.
+func (a *adaptiveLimit) reset(rate float64, grown bool) {
+	a.done, a.bytes, a.rate, a.grown = 0, 0, rate, grown
+}
+
+// Get the current limit, zero for nil one.
+func (a *adaptiveLimit) current() int {
+	if a == nil {
+		return 0
+	}
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.limit
+}
+
+// Check if the error means the host is overloaded: timeouts, rate limiting and unavailable service.
+func congested(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == http.StatusTooManyRequests || statusErr.Code == http.StatusServiceUnavailable ||
+			statusErr.Code == http.StatusRequestTimeout || statusErr.Code == http.StatusGatewayTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
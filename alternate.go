@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Preferred format of the episodes offered in several ones with podcast:alternateEnclosure.
+type FormatConfig struct {
+	// MIME types in the order of preference, like ["audio/opus", "audio/mpeg"].
+	Types []string `json:"types,omitempty"`
+	// Maximum bitrate in bits per second, the highest one below it is preferred.
+	Bitrate int `json:"bitrate,omitempty"`
+}
+
+// Media file of the episode with the integrity hash if it's provided.
+type media struct {
+	*gofeed.Enclosure
+	Bitrate int
+	// Subresource integrity value, like sha384-<base64>.
+	Integrity string
+}
+
+// Get the podcast:alternateEnclosure files of the item with http(s) sources.
+func alternateEnclosures(item *gofeed.Item) []media {
+	result := make([]media, 0)
+	for _, ext := range item.Extensions["podcast"]["alternateEnclosure"] {
+		m := media{Enclosure: &gofeed.Enclosure{Type: ext.Attrs["type"], Length: ext.Attrs["length"]}}
+		m.Bitrate, _ = strconv.Atoi(strings.Split(ext.Attrs["bitrate"], ".")[0])
+		for _, src := range ext.Children["source"] {
+			uri := src.Attrs["uri"]
+			if strings.HasPrefix(uri, "https://") || strings.HasPrefix(uri, "http://") {
+				m.URL = uri
+				break
+			}
+		}
+		for _, integrity := range ext.Children["integrity"] {
+			if integrity.Attrs["type"] == "sri" {
+				m.Integrity = integrity.Attrs["value"]
+			}
+		}
+		if len(m.URL) > 0 {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Get the media file of the item in the preferred format, the enclosure by default.
+func (dl *Glsdl) itemMedia(item *gofeed.Item) (media, bool) {
+	e, ok := itemEnclosure(item)
+	if !ok {
+		return media{}, false
+	}
+	// The integrity of the enclosure may be given by its alternate copy.
+	main := media{Enclosure: e}
+	alternates := alternateEnclosures(item)
+	for _, a := range alternates {
+		if a.URL == e.URL {
+			main.Bitrate, main.Integrity = a.Bitrate, a.Integrity
+		}
+	}
+	prefer := dl.conf.Format
+	if prefer == nil || len(alternates) == 0 {
+		return main, true
+	}
+	candidates := append([]media{main}, alternates...)
+	rank := func(m media) int {
+		for i, t := range prefer.Types {
+			if strings.EqualFold(t, m.Type) {
+				return i
+			}
+		}
+		return len(prefer.Types)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		if prefer.Bitrate > 0 && a.Bitrate != b.Bitrate {
+			// Under the limit the higher bitrate is better, above the limit the lower one.
+			underA, underB := a.Bitrate <= prefer.Bitrate, b.Bitrate <= prefer.Bitrate
+			if underA != underB {
+				return underA
+			}
+			return (a.Bitrate > b.Bitrate) == underA
+		}
+		return false
+	})
+	return candidates[0], true
+}
+
+// Hash functions of the subresource integrity algorithms from the weakest to the strongest.
+var integrityAlgos = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"sha256", sha256.New},
+	{"sha384", sha512.New384},
+	{"sha512", sha512.New},
+}
+
+// Check the file against the subresource integrity value: sha256-, sha384- or sha512- base64 digest.
+// The value may list several digests separated by spaces, the strongest one is checked.
+func verifyIntegrity(filename, integrity string) error {
+	var h hash.Hash
+	digest, strength := "", -1
+	for _, value := range strings.Fields(integrity) {
+		algo, d, _ := strings.Cut(value, "-")
+		// Options like sha256-<digest>?ct=audio/mpeg are ignored.
+		d, _, _ = strings.Cut(d, "?")
+		for i, a := range integrityAlgos {
+			if a.name == algo && i > strength {
+				h, digest, strength = a.hash(), d, i
+			}
+		}
+	}
+	if h == nil {
+		return fmt.Errorf("unsupported integrity value %q", integrity)
+	}
+	fh, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	if _, err := io.Copy(h, fh); err != nil {
+		return err
+	}
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != digest {
+		return fmt.Errorf("%s: integrity check failed", filename)
+	}
+	return nil
+}
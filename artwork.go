@@ -0,0 +1,131 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"strconv"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Name of the canonical cover of the feed in the download directory, it may contain JPEG data too.
+const CoverFile = "cover.png"
+
+// Resized variants of the cover for the players by filename.
+var coverVariants = []struct {
+	Name string
+	Size int
+}{
+	{"folder.jpg", 500},
+	{"thumb.jpg", 150},
+}
+
+// Get the artwork URLs of the feed: RSS image and iTunes image, which is usually larger.
+func feedImages(feed *gofeed.Feed) []string {
+	urls := make([]string, 0, 2)
+	if feed.Image != nil && len(feed.Image.URL) > 0 {
+		urls = append(urls, feed.Image.URL)
+	}
+	if feed.ITunesExt != nil && len(feed.ITunesExt.Image) > 0 && (len(urls) == 0 || urls[0] != feed.ITunesExt.Image) {
+		urls = append(urls, feed.ITunesExt.Image)
+	}
+	return urls
+}
+
+// Download the artwork of the feed, keep the one of the highest resolution as the cover
+// and write its resized variants. Returns false if the feed has no artwork.
+func (dl *Glsdl) downloadCover(feed *gofeed.Feed) (bool, error) {
+	urls := feedImages(feed)
+	if len(urls) == 0 {
+		return false, nil
+	}
+	best, bestPixels := "", -1
+	var lastErr error
+	for i, u := range urls {
+		tmp := dl.downloadDir + ps + CoverFile + "." + strconv.Itoa(i) + ".tmp"
+		if _, err := dl.downloadFile(u, tmp); err != nil {
+			lastErr = err
+			continue
+		}
+		pixels := imagePixels(tmp)
+		if pixels > bestPixels {
+			if len(best) > 0 {
+				_ = os.Remove(best)
+			}
+			best, bestPixels = tmp, pixels
+		} else {
+			_ = os.Remove(tmp)
+		}
+	}
+	if len(best) == 0 {
+		return true, lastErr
+	}
+	cover := dl.downloadDir + ps + CoverFile
+	if err := os.Rename(best, cover); err != nil {
+		return true, err
+	}
+	return true, writeCoverVariants(cover, dl.downloadDir)
+}
+
+// Get the number of pixels of the image file, zero if it can't be decoded.
+func imagePixels(filename string) int {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return 0
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	conf, _, err := image.DecodeConfig(fh)
+	if err != nil {
+		return 0
+	}
+	return conf.Width * conf.Height
+}
+
+// Write the resized JPEG variants of the cover to the directory.
+func writeCoverVariants(cover, dir string) error {
+	img, err := decodeImage(cover)
+	if err != nil {
+		return err
+	}
+	for _, v := range coverVariants {
+		if err := writeJPEG(resizeImage(img, v.Size), dir+ps+v.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Downscale the image to fit the square of the size keeping the aspect ratio, averaging the source pixels.
+// Smaller images are kept as is.
+func resizeImage(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return src
+	}
+	nw, nh := size, size
+	if w > h {
+		nh = max(1, h*size/w)
+	} else {
+		nw = max(1, w*size/h)
+	}
+	dst := image.NewRGBA64(image.Rect(0, 0, nw, nh))
+	for y := 0; y < nh; y++ {
+		y0, y1 := b.Min.Y+y*h/nh, b.Min.Y+(y+1)*h/nh
+		for x := 0; x < nw; x++ {
+			x0, x1 := b.Min.X+x*w/nw, b.Min.X+(x+1)*w/nw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < max(y1, y0+1); sy++ {
+				for sx := x0; sx < max(x1, x0+1); sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a, n = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca), n+1
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth2 settings of the private feed, like membership feeds of Supercast or Patreon.
+type AuthConfig struct {
+	// Device authorization and token endpoints (RFC 8628).
+	DeviceURL string `json:"device_url"`
+	TokenURL  string `json:"token_url"`
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope,omitempty"`
+	// Hosts receiving the token in addition to the directory of the feed URL, like the media CDN,
+	// optionally limited by the path prefix: cdn.example.com/show/.
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Name of the token store kept next to the config file.
+const TokensFile = "tokens.json"
+
+// OAuth2 token of the feed.
+type authToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitempty"`
+}
+
+// Token endpoint response.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	Error        string `json:"error"`
+	Description  string `json:"error_description"`
+}
+
+// Persistent tokens of the feeds by the feed name, readable by the owner only.
+type authStore struct {
+	path   string
+	mux    sync.Mutex
+	Tokens map[string]*authToken `json:"tokens"`
+}
+
+// Load the tokens from the file. Missing file means no tokens.
+func loadAuthStore(path string) (*authStore, error) {
+	s := &authStore{path: path, Tokens: make(map[string]*authToken)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Tokens == nil {
+		s.Tokens = make(map[string]*authToken)
+	}
+	return s, nil
+}
+
+// Write the tokens to the file.
+func (s *authStore) Save() error {
+	s.mux.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mux.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	return durable(s.path)
+}
+
+// Get the valid access token of the feed, the expired token is refreshed.
+func (s *authStore) token(feed *FeedConfig) (string, error) {
+	s.mux.Lock()
+	tok, ok := s.Tokens[feed.Name]
+	s.mux.Unlock()
+	if !ok {
+		return "", fmt.Errorf("feed %s isn't authorized, run auth command", feed.Name)
+	}
+	if tok.Expiry.IsZero() || time.Now().Add(time.Minute).Before(tok.Expiry) {
+		return tok.AccessToken, nil
+	}
+	if len(tok.RefreshToken) == 0 {
+		return "", fmt.Errorf("token of feed %s expired, run auth command", feed.Name)
+	}
+	resp, err := requestToken(feed.Auth.TokenURL, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tok.RefreshToken},
+		"client_id":     {feed.Auth.ClientID},
+	})
+	if err != nil {
+		return "", fmt.Errorf("feed %s: refresh token: %w", feed.Name, err)
+	}
+	fresh := newAuthToken(resp)
+	// The refresh token may be kept by the server.
+	if len(fresh.RefreshToken) == 0 {
+		fresh.RefreshToken = tok.RefreshToken
+	}
+	s.mux.Lock()
+	s.Tokens[feed.Name] = fresh
+	s.mux.Unlock()
+	return fresh.AccessToken, s.Save()
+}
+
+// Authorize the feed with OAuth2 device flow: the user enters the code on the verification page
+// while the token endpoint is polled.
+func (s *authStore) Authorize(feed *FeedConfig, out io.Writer) error {
+	if feed.Auth == nil {
+		return errors.New(msg("auth.none", feed.Name))
+	}
+	form := url.Values{"client_id": {feed.Auth.ClientID}}
+	if len(feed.Auth.Scope) > 0 {
+		form.Set("scope", feed.Auth.Scope)
+	}
+	var device struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		// Verification page with the code filled in.
+		VerificationURIComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	if err := postForm(feed.Auth.DeviceURL, form, &device); err != nil {
+		return err
+	}
+	if len(device.DeviceCode) == 0 {
+		return errors.New("device authorization: no device code")
+	}
+	page := device.VerificationURI
+	if len(device.VerificationURIComplete) > 0 {
+		page = device.VerificationURIComplete
+	}
+	_, _ = fmt.Fprintln(out, msg("auth.prompt", page, device.UserCode))
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	if device.ExpiresIn <= 0 {
+		deadline = time.Now().Add(15 * time.Minute)
+	}
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		resp, err := requestToken(feed.Auth.TokenURL, url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {device.DeviceCode},
+			"client_id":   {feed.Auth.ClientID},
+		})
+		var tokenErr *oauthError
+		switch {
+		case errors.As(err, &tokenErr) && tokenErr.Code == "authorization_pending":
+			continue
+		case errors.As(err, &tokenErr) && tokenErr.Code == "slow_down":
+			interval += 5 * time.Second
+			continue
+		case err != nil:
+			return err
+		}
+		s.mux.Lock()
+		s.Tokens[feed.Name] = newAuthToken(resp)
+		s.mux.Unlock()
+		if err := s.Save(); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, msg("auth.done", feed.Name))
+		return nil
+	}
+	return errors.New("device authorization expired")
+}
+
+// Error of the token endpoint.
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func (e *oauthError) Error() string {
+	if len(e.Description) > 0 {
+		return "oauth: " + e.Code + ": " + e.Description
+	}
+	return "oauth: " + e.Code
+}
+
+// Request the token from the endpoint.
+func requestToken(endpoint string, form url.Values) (*tokenResponse, error) {
+	var resp tokenResponse
+	err := postForm(endpoint, form, &resp)
+	if len(resp.Error) > 0 {
+		return nil, &oauthError{Code: resp.Error, Description: resp.Description}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.AccessToken) == 0 {
+		return nil, errors.New("oauth: no access token")
+	}
+	return &resp, nil
+}
+
+// Post the form and decode JSON response. OAuth2 errors come with 400 status, so the body is decoded anyway.
+func postForm(endpoint string, form url.Values, v interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &httpStatusError{URL: endpoint, Code: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
+
+func newAuthToken(resp *tokenResponse) *authToken {
+	tok := &authToken{AccessToken: resp.AccessToken, RefreshToken: resp.RefreshToken}
+	if resp.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return tok
+}
+
+// Transport adding the bearer tokens to the requests of the authorized hosts.
+// The tokens are kept by the host and path prefix, so the feeds of one host don't share them.
+type authTransport struct {
+	base   http.RoundTripper
+	mux    sync.RWMutex
+	tokens map[string]string
+}
+
+func newAuthTransport(base http.RoundTripper) *authTransport {
+	return &authTransport{base: base, tokens: make(map[string]string)}
+}
+
+// Send the token to the directory of the feed URL and the configured hosts.
+func (t *authTransport) set(feed *FeedConfig, token string) {
+	scopes := make([]string, 0, len(feed.Auth.Hosts)+1)
+	for _, host := range feed.Auth.Hosts {
+		if !strings.Contains(host, "/") {
+			host += "/"
+		}
+		scopes = append(scopes, host)
+	}
+	if u, err := url.Parse(feed.URL); err == nil && len(u.Host) > 0 {
+		dir := u.EscapedPath()
+		dir = dir[:strings.LastIndex(dir, "/")+1]
+		scopes = append(scopes, u.Hostname()+"/"+strings.TrimPrefix(dir, "/"))
+	}
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for _, scope := range scopes {
+		t.tokens[strings.ToLower(scope)] = token
+	}
+}
+
+// Get the token of the longest scope matching the URL.
+func (t *authTransport) token(u *url.URL) (token string, ok bool) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	target, longest := strings.ToLower(u.Hostname()+u.EscapedPath()), 0
+	for scope, tok := range t.tokens {
+		if len(scope) > longest && strings.HasPrefix(target+"/", scope) {
+			token, ok, longest = tok, true, len(scope)
+		}
+	}
+	return
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, ok := t.token(req.URL)
+	// Don't leak the token over plain HTTP.
+	if !ok || req.URL.Scheme != "https" || len(req.Header.Get("Authorization")) > 0 {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// Device on the LAN able to play media by URL.
+type renderer interface {
+	// Host of the device, used to pick the local address reachable by it.
+	Host() string
+	Name() string
+	Play(mediaURL, title, artist string) error
+	Stop() error
+}
+
+// Stream the archived episode to the DLNA renderer or Chromecast until interrupted.
+// Device is a DLNA renderer name (the first found one if empty) or cast://host[:port] for Chromecast.
+func (dl *Glsdl) Cast(query, device string, out io.Writer) error {
+	episode, err := dl.findEpisode(query)
+	if err != nil {
+		return err
+	}
+
+	var r renderer
+	if strings.HasPrefix(device, "cast://") {
+		r, err = dialChromecast(strings.TrimPrefix(device, "cast://"))
+	} else {
+		r, err = findDLNARenderer(device)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Serve the episode to the device from the address it can reach.
+	conn, err := net.Dial("udp", net.JoinHostPort(r.Host(), "9"))
+	if err != nil {
+		return err
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+	ln, err := net.Listen("tcp", net.JoinHostPort(localIP.String(), "0"))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ln.Close()
+	}()
+	filename := dl.downloadDir + ps + episode.Filename
+	mux := http.NewServeMux()
+	mux.HandleFunc("/episode.mp3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		http.ServeFile(w, r, filename)
+	})
+	go func() {
+		_ = http.Serve(ln, mux)
+	}()
+	mediaURL := (&url.URL{Scheme: "http", Host: ln.Addr().String(), Path: "/episode.mp3"}).String()
+
+	_, finalTitle := dl.itemFilename(episode.Item)
+	if err := r.Play(mediaURL, finalTitle, dl.itemArtist(episode.Item)); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, msg("cast.playing", finalTitle, r.Name()))
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	signal.Stop(sig)
+
+	return r.Stop()
+}
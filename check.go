@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Print the pending work without doing it: episodes to download, to download again, corrupt files
+// to quarantine, files to retag and played episodes to delete by retention.
+// Porcelain format is one line per episode with tab-separated action, number and filename.
+func (dl *Glsdl) Check(out io.Writer) error {
+	feed, err := dl.parseFeed()
+	if err != nil {
+		return err
+	}
+	pending := 0
+	report := func(action, number, filename, title string) {
+		pending++
+		if dl.porcelain {
+			_, _ = fmt.Fprintf(out, "%s\t%s\t%s\n", action, porcelainField(number), porcelainField(filename))
+			return
+		}
+		_, _ = fmt.Fprintln(out, "*", msg("check."+action, title))
+	}
+
+	for _, item := range feed.Items {
+		enclosure, ok := dl.itemMedia(item)
+		if !ok || !dl.wanted(item) {
+			continue
+		}
+		key := itemKey(item)
+		e, recorded := dl.state.Get(key)
+		if e.Deleted || (e.duplicated() && !dl.fileExists(e.Filename)) {
+			continue
+		}
+		prefix, _ := dl.parseTitle(item)
+		filename, finalTitle := dl.itemFilename(item)
+		if recorded && dl.fileExists(e.Filename) {
+			filename = dl.downloadDir + ps + e.Filename
+		}
+		switch {
+		case !dl.fileExists(dl.relName(filename)):
+			report("download", prefix, dl.relName(filename), finalTitle)
+		case recorded && episodeUpdated(e, enclosure.Enclosure, item) && dl.redownload:
+			report("redownload", prefix, dl.relName(filename), finalTitle)
+		case !isContainerFile(filename) && corruptMP3(filename):
+			report("corrupt", prefix, dl.relName(filename), finalTitle)
+		case dl.needsRetag(filename, item, finalTitle):
+			report("retag", prefix, dl.relName(filename), finalTitle)
+		}
+	}
+
+	if dl.conf.DeletePlayed > 0 {
+		deadline := time.Now().AddDate(0, 0, -dl.conf.DeletePlayed)
+		for _, a := range dl.archivedItems() {
+			e, _ := dl.state.Get(itemKey(a.Item))
+			if e.Played != nil && e.Played.Before(deadline) {
+				prefix, _ := dl.parseTitle(a.Item)
+				report("prune", prefix, a.Filename, e.Title)
+			}
+		}
+	}
+
+	if pending == 0 && !dl.porcelain {
+		_, _ = fmt.Fprintln(out, msg("check.none"))
+	}
+	return nil
+}
+
+// Check if the ID3 tags of the file differ from the ones the fetch would write.
+// Files tagged with ffmpeg aren't checked.
+func (dl *Glsdl) needsRetag(filename string, item *gofeed.Item, title string) bool {
+	if isContainerFile(filename) {
+		return false
+	}
+	tag, err := ReadID3(filename)
+	if err != nil {
+		return true
+	}
+	return tag.Text("TIT2") != title ||
+		tag.Text("TPE1") != dl.itemArtist(item) ||
+		tag.Text("TALB") != dl.itemAlbum(item)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Chromecast protocol (CASTV2) namespaces and the default media receiver app.
+const (
+	castNsConnection = "urn:x-cast:com.google.cast.tp.connection"
+	castNsHeartbeat  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	castNsReceiver   = "urn:x-cast:com.google.cast.receiver"
+	castNsMedia      = "urn:x-cast:com.google.cast.media"
+	castMediaApp     = "CC1AD845"
+	castSender       = "sender-glsdl"
+	castReceiver     = "receiver-0"
+	castTimeout      = 15 * time.Second
+)
+
+// Chromecast device speaking CASTV2: length-prefixed protobuf CastMessage over TLS.
+type chromecast struct {
+	host      string
+	conn      net.Conn
+	mux       sync.Mutex
+	requestID int
+	transport string
+	session   int
+	done      chan struct{}
+	messages  chan castMessage
+}
+
+// CastMessage protobuf, only string payloads are used.
+type castMessage struct {
+	Source      string
+	Destination string
+	Namespace   string
+	Payload     string
+}
+
+// Connect to Chromecast at host[:port].
+func dialChromecast(address string) (*chromecast, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = address, "8009"
+	}
+	dialer := &net.Dialer{Timeout: castTimeout}
+	// Chromecast uses self-signed certificates.
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	c := &chromecast{
+		host:     host,
+		conn:     conn,
+		done:     make(chan struct{}),
+		messages: make(chan castMessage, 16),
+	}
+	go c.readLoop()
+	if err := c.send(castReceiver, castNsConnection, map[string]interface{}{"type": "CONNECT"}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *chromecast) Host() string { return c.host }
+func (c *chromecast) Name() string { return "Chromecast " + c.host }
+
+func (c *chromecast) Play(mediaURL, title, artist string) error {
+	// Launch the default media receiver and wait for its transport ID.
+	if err := c.send(castReceiver, castNsReceiver, map[string]interface{}{"type": "LAUNCH", "appId": castMediaApp}); err != nil {
+		return err
+	}
+	var status struct {
+		Type   string `json:"type"`
+		Status struct {
+			Applications []struct {
+				AppID       string `json:"appId"`
+				TransportID string `json:"transportId"`
+			} `json:"applications"`
+		} `json:"status"`
+	}
+	err := c.wait(func(m castMessage) bool {
+		if m.Namespace != castNsReceiver || json.Unmarshal([]byte(m.Payload), &status) != nil {
+			return false
+		}
+		for _, app := range status.Status.Applications {
+			if app.AppID == castMediaApp && len(app.TransportID) > 0 {
+				c.transport = app.TransportID
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := c.send(c.transport, castNsConnection, map[string]interface{}{"type": "CONNECT"}); err != nil {
+		return err
+	}
+	load := map[string]interface{}{
+		"type": "LOAD",
+		"media": map[string]interface{}{
+			"contentId":   mediaURL,
+			"contentType": "audio/mpeg",
+			"streamType":  "BUFFERED",
+			"metadata": map[string]interface{}{
+				"metadataType": 3,
+				"title":        title,
+				"artist":       artist,
+			},
+		},
+		"autoplay": true,
+	}
+	if err := c.send(c.transport, castNsMedia, load); err != nil {
+		return err
+	}
+	var media struct {
+		Type   string `json:"type"`
+		Status []struct {
+			MediaSessionID int `json:"mediaSessionId"`
+		} `json:"status"`
+	}
+	err = c.wait(func(m castMessage) bool {
+		if m.Namespace != castNsMedia || json.Unmarshal([]byte(m.Payload), &media) != nil {
+			return false
+		}
+		if media.Type == "MEDIA_STATUS" && len(media.Status) > 0 {
+			c.session = media.Status[0].MediaSessionID
+			return true
+		}
+		return media.Type == "LOAD_FAILED" || media.Type == "INVALID_REQUEST"
+	})
+	if err == nil && c.session == 0 {
+		err = fmt.Errorf("chromecast: %s", media.Type)
+	}
+	return err
+}
+
+func (c *chromecast) Stop() error {
+	defer func() {
+		_ = c.conn.Close()
+	}()
+	if len(c.transport) == 0 || c.session == 0 {
+		return nil
+	}
+	return c.send(c.transport, castNsMedia, map[string]interface{}{"type": "STOP", "mediaSessionId": c.session})
+}
+
+// Wait for the message matched by the function.
+func (c *chromecast) wait(match func(m castMessage) bool) error {
+	timeout := time.After(castTimeout)
+	for {
+		select {
+		case m := <-c.messages:
+			if match(m) {
+				return nil
+			}
+		case <-c.done:
+			return errors.New("chromecast: connection closed")
+		case <-timeout:
+			return errors.New("chromecast: response timed out")
+		}
+	}
+}
+
+// Read the messages, reply to heartbeat pings and pass the rest to wait.
+func (c *chromecast) readLoop() {
+	defer close(c.done)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(c.conn, data); err != nil {
+			return
+		}
+		m, err := decodeCastMessage(data)
+		if err != nil {
+			continue
+		}
+		if m.Namespace == castNsHeartbeat {
+			_ = c.send(m.Source, castNsHeartbeat, map[string]interface{}{"type": "PONG"})
+			continue
+		}
+		select {
+		case c.messages <- m:
+		default:
+		}
+	}
+}
+
+// Send JSON payload to the destination.
+func (c *chromecast) send(dest, namespace string, payload map[string]interface{}) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if payload["type"] != "PONG" && payload["type"] != "CONNECT" {
+		c.requestID++
+		payload["requestId"] = c.requestID
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	msg := encodeCastMessage(castMessage{Source: castSender, Destination: dest, Namespace: namespace, Payload: string(data)})
+	buf := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(buf, uint32(len(msg)))
+	_ = c.conn.SetWriteDeadline(time.Now().Add(castTimeout))
+	_, err = c.conn.Write(append(buf, msg...))
+	return err
+}
+
+// Encode CastMessage protobuf: protocol_version=1, source_id=2, destination_id=3, namespace=4,
+// payload_type=5 (STRING), payload_utf8=6.
+func encodeCastMessage(m castMessage) []byte {
+	buf := []byte{0x08, 0x00}
+	for i, s := range []string{m.Source, m.Destination, m.Namespace} {
+		buf = appendProtoString(buf, byte(i+2), s)
+	}
+	buf = append(buf, 0x28, 0x00)
+	return appendProtoString(buf, 6, m.Payload)
+}
+
+// Append length-delimited protobuf field.
+func appendProtoString(buf []byte, field byte, s string) []byte {
+	buf = append(buf, field<<3|2)
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// Decode CastMessage protobuf, binary payloads are skipped.
+func decodeCastMessage(data []byte) (castMessage, error) {
+	var m castMessage
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return m, errors.New("chromecast: broken message")
+		}
+		data = data[n:]
+		switch key & 7 {
+		case 0:
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return m, errors.New("chromecast: broken message")
+			}
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return m, errors.New("chromecast: broken message")
+			}
+			value := string(data[n : n+int(size)])
+			data = data[n+int(size):]
+			switch key >> 3 {
+			case 2:
+				m.Source = value
+			case 3:
+				m.Destination = value
+			case 4:
+				m.Namespace = value
+			case 6:
+				m.Payload = value
+			}
+		default:
+			return m, fmt.Errorf("chromecast: unsupported wire type %d", key&7)
+		}
+	}
+	return m, nil
+}
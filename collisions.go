@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Find the distinct items resolving to the same filename (same number, duplicate titles) and
+// disambiguate them: the oldest item keeps the name, the next ones get the publishing date suffix,
+// like "042 - Title (2006-01-02).mp3", or the short GUID one if the date doesn't help.
+// The names are compared case-insensitively, since the file systems may be case-insensitive.
+func (dl *Glsdl) buildCollisions(feed *gofeed.Feed) {
+	dl.suffixes = make(map[string]string)
+	seen := make(map[string]string)
+	for _, item := range chronologicalItems(feed) {
+		key := itemKey(item)
+		filename, _ := dl.itemFilename(item)
+		name := strings.ToLower(filename)
+		if owner, ok := seen[name]; !ok || owner == key {
+			seen[name] = key
+			continue
+		}
+		ext := dl.itemExt(item)
+		base := strings.TrimSuffix(filename, ext)
+		suffix := ""
+		if published := itemPublished(item); !published.IsZero() {
+			suffix = " (" + published.Format("2006-01-02") + ")"
+		}
+		if _, ok := seen[strings.ToLower(base+suffix+ext)]; len(suffix) == 0 || ok {
+			suffix = " [" + shortGUID(item) + "]"
+		}
+		dl.suffixes[key] = suffix
+		seen[strings.ToLower(base+suffix+ext)] = key
+	}
+}
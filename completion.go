@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Hidden command printing the configured feed names for the completion scripts.
+const feedsCommand = "__feeds"
+
+// Flags taking file paths.
+var fileFlags = map[string]bool{"config": true, "target": true, "socket": true}
+
+// Write the completion script for the shell: bash, zsh or fish.
+// Feed names are completed by calling glsdl itself, so they follow the config changes.
+func writeCompletion(shell string, out io.Writer) error {
+	type flagInfo struct {
+		name, usage string
+		isBool      bool
+	}
+	flags := make([]flagInfo, 0)
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, flagInfo{name: f.Name, usage: f.Usage, isBool: ok && b.IsBoolFlag()})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].name < flags[j].name })
+	names := make([]string, 0, len(flags))
+	for _, f := range flags {
+		names = append(names, "-"+f.name)
+	}
+	files := make([]string, 0)
+	for name := range fileFlags {
+		files = append(files, "-"+name)
+	}
+	sort.Strings(files)
+	cmds := strings.Join(commands, " ")
+
+	switch shell {
+	case "bash":
+		_, err := fmt.Fprintf(out, `_glsdl() {
+  local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+  case "$prev" in
+    -feed) COMPREPLY=($(compgen -W "$(glsdl %s 2>/dev/null)" -- "$cur")); return;;
+    %s) COMPREPLY=($(compgen -f -- "$cur")); return;;
+  esac
+  if [[ "$cur" == -* ]]; then
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+  else
+    COMPREPLY=($(compgen -W "%s" -- "$cur"))
+  fi
+}
+complete -F _glsdl glsdl
+`, feedsCommand, strings.Join(files, "|"), strings.Join(names, " "), cmds)
+		return err
+	case "zsh":
+		described := make([]string, 0, len(flags))
+		for _, f := range flags {
+			described = append(described, "'-"+f.name+":"+zshEscape(f.usage)+"'")
+		}
+		_, err := fmt.Fprintf(out, `#compdef glsdl
+_glsdl() {
+  local -a cmds flags
+  cmds=(%s)
+  flags=(%s)
+  case $words[CURRENT-1] in
+    -feed) compadd -- ${(f)"$(glsdl %s 2>/dev/null)"}; return;;
+    %s) _files; return;;
+  esac
+  if [[ $PREFIX == -* ]]; then
+    _describe 'flag' flags
+  else
+    compadd -- $cmds
+  fi
+}
+compdef _glsdl glsdl
+`, cmds, strings.Join(described, " "), feedsCommand, strings.Join(files, "|"))
+		return err
+	case "fish":
+		var b strings.Builder
+		b.WriteString("complete -c glsdl -f\n")
+		b.WriteString("complete -c glsdl -n __fish_use_subcommand -a '" + cmds + "'\n")
+		for _, f := range flags {
+			line := "complete -c glsdl -o " + f.name + " -d " + fishQuote(f.usage)
+			switch {
+			case f.name == "feed":
+				line += " -xa '(glsdl " + feedsCommand + " 2>/dev/null)'"
+			case fileFlags[f.name]:
+				line += " -rF"
+			case !f.isBool:
+				line += " -x"
+			}
+			b.WriteString(line + "\n")
+		}
+		_, err := io.WriteString(out, b.String())
+		return err
+	}
+	return fmt.Errorf("%s", msg("completion.unknown", shell))
+}
+
+// Escape the _describe item description.
+func zshEscape(s string) string {
+	return strings.NewReplacer(":", `\:`, "'", `'\''`).Replace(s)
+}
+
+// Quote the string for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Default title pattern of GolangShow episodes, like "Выпуск 042. Title".
+const DefaultPattern = `^(?:Выпуск|Episode)\s+(?P<number>[[:alnum:]]+)\.*\s*(?P<title>.*?)$`
+
+// Config file structure.
+type Config struct {
+	// Directory containing the download directories of the feeds, ~/Music/Podcast by default.
+	Dir string `json:"dir,omitempty"`
+	// Directory containing the download directories of the video feeds, ~/Videos/Podcast by default.
+	VideoDir string `json:"video_dir,omitempty"`
+	// Directory to download the episodes to before moving them to the library, -temp-dir flag overrides it.
+	TempDir string `json:"temp_dir,omitempty"`
+	// Threads to download media files, -t flag overrides it.
+	Threads int           `json:"threads,omitempty"`
+	Feeds   []*FeedConfig `json:"feeds"`
+	// Update MPD database after the run.
+	MPD *MPDConfig `json:"mpd,omitempty"`
+	// Media servers scanning the library after new episodes are downloaded.
+	MediaServers []*MediaServerConfig `json:"media_servers,omitempty"`
+	// Local player used by play command.
+	Player *PlayerConfig `json:"player,omitempty"`
+	// Notifications of new episodes.
+	Notify []*NotifierConfig `json:"notify,omitempty"`
+}
+
+// Settings of one feed subscription.
+type FeedConfig struct {
+	// Name of the feed, also used as a download directory name.
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Title parsing patterns tried in order.
+	// Named capture groups "number" and "title" extract the episode number and title.
+	Patterns []string `json:"patterns,omitempty"`
+	// Tag values used when the feed doesn't provide them.
+	Defaults TagConfig `json:"defaults"`
+	// Tag values forced regardless of the feed.
+	Tags TagConfig `json:"tags"`
+	// Wipe existing tags of the episodes before writing new ones.
+	Strip bool `json:"strip,omitempty"`
+	// Delete the files of episodes played more than this number of days ago.
+	DeletePlayed int `json:"delete_played,omitempty"`
+	// Download directory of the feed, <dir>/<name> by default.
+	Dir string `json:"dir,omitempty"`
+	// Filename template, -template flag overrides it.
+	Template string `json:"template,omitempty"`
+	// Maximum workers of the feed in the shared pool.
+	Threads int `json:"threads,omitempty"`
+	// Episodes which titles match any of include patterns (if set) and none of exclude ones are downloaded.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Downloaded itunes:episodeType values (full, trailer, bonus), all by default. Items without the type are full.
+	EpisodeTypes []string `json:"episode_types,omitempty"`
+	// Episodes with any of these iTunes keywords or categories are skipped, case-insensitive.
+	SkipKeywords []string `json:"skip_keywords,omitempty"`
+	// Bandwidth cap of the feed downloads, like "500K" per second.
+	Rate string `json:"rate,omitempty"`
+	// Video show, downloaded to the video directory unless only the audio is kept.
+	Video bool `json:"video,omitempty"`
+	// Extract the audio track of the video episodes to M4A files.
+	AudioOnly bool `json:"audio_only,omitempty"`
+	// Preferred format of the episodes offered in several ones.
+	Format *FormatConfig `json:"format,omitempty"`
+	// Disabled feed isn't processed unless it's selected by -feed flag.
+	Disabled bool `json:"disabled,omitempty"`
+	// OAuth2 settings of the private feed.
+	Auth *AuthConfig `json:"auth,omitempty"`
+
+	patterns []*regexp.Regexp
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+	dir      string
+	limiter  *rateLimiter
+	// Source given by -feed flag.
+	source Source
+}
+
+// Get the default config with the GolangShow feed.
+func DefaultConfig() *Config {
+	return &Config{
+		Feeds: []*FeedConfig{
+			{
+				Name:     "GolangShow",
+				URL:      GlsFeed,
+				Defaults: TagConfig{Album: "GolangShow", Genre: "Technology"},
+			},
+		},
+	}
+}
+
+// Load the config from the file. Missing file means default config.
+func LoadConfig(path string) (*Config, error) {
+	conf := DefaultConfig()
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		conf = &Config{}
+		if err := json.Unmarshal(data, conf); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if err := conf.init(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// Validate the config and compile the patterns.
+func (c *Config) init() error {
+	if len(c.Feeds) == 0 {
+		return fmt.Errorf("no feeds configured")
+	}
+	root := expandHome(c.Dir)
+	if len(root) == 0 {
+		root = DefaultDir()
+	}
+	videoRoot := expandHome(c.VideoDir)
+	if len(videoRoot) == 0 {
+		videoRoot = DefaultVideoDir()
+	}
+	for _, n := range c.Notify {
+		if err := n.init(); err != nil {
+			return err
+		}
+	}
+	for _, s := range c.MediaServers {
+		if err := s.init(); err != nil {
+			return err
+		}
+	}
+	for i, feed := range c.Feeds {
+		if len(feed.Name) == 0 || len(feed.URL) == 0 {
+			return fmt.Errorf("feed #%d: name and url are required", i)
+		}
+		feed.dir = root + ps + sanitizeName(feed.Name)
+		if feed.Video && !feed.AudioOnly {
+			feed.dir = videoRoot + ps + sanitizeName(feed.Name)
+		}
+		if len(feed.Dir) > 0 {
+			feed.dir = expandHome(feed.Dir)
+		}
+		var err error
+		if feed.include, err = compilePatterns(feed.Include); err != nil {
+			return fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+		if feed.exclude, err = compilePatterns(feed.Exclude); err != nil {
+			return fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+		rate, err := parseSize(feed.Rate)
+		if err != nil {
+			return fmt.Errorf("feed %s: %w", feed.Name, err)
+		}
+		feed.limiter = newRateLimiter(rate)
+		if feed.Auth != nil && (len(feed.Auth.DeviceURL) == 0 || len(feed.Auth.TokenURL) == 0 || len(feed.Auth.ClientID) == 0) {
+			return fmt.Errorf("feed %s: auth requires device_url, token_url and client_id", feed.Name)
+		}
+		patterns := feed.Patterns
+		if len(patterns) == 0 {
+			patterns = []string{DefaultPattern}
+		}
+		feed.patterns = feed.patterns[:0]
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return fmt.Errorf("feed %s: %w", feed.Name, err)
+			}
+			if re.SubexpIndex("number") < 0 && re.SubexpIndex("title") < 0 {
+				return fmt.Errorf("feed %s: pattern %q has neither number nor title group", feed.Name, p)
+			}
+			feed.patterns = append(feed.patterns, re)
+		}
+	}
+	return nil
+}
+
+// Check if the episode title passes include and exclude filters.
+func (f *FeedConfig) match(title string) bool {
+	for _, re := range f.exclude {
+		if re.MatchString(title) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(title) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compile the list of patterns.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// Replace the leading ~ of the path with the home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + strings.TrimPrefix(path, "~")
+}
+
+// Get the feeds with the name.
+func (c *Config) filter(name string) ([]*FeedConfig, error) {
+	for _, feed := range c.Feeds {
+		if feed.Name == name {
+			return []*FeedConfig{feed}, nil
+		}
+	}
+	return nil, fmt.Errorf("feed %s not found", name)
+}
+
+// Get the default directory containing the download directories of the feeds.
+func DefaultDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "Podcast"
+	}
+	return strings.Join([]string{home, "Music", "Podcast"}, ps)
+}
+
+// Get the default directory containing the download directories of the video feeds.
+func DefaultVideoDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "Video Podcast"
+	}
+	return strings.Join([]string{home, "Videos", "Podcast"}, ps)
+}
+
+// Get the default path of the config file.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "glsdl.json"
+	}
+	return dir + ps + "glsdl" + ps + "config.json"
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Persistent cookie jar stored in Netscape cookies.txt format, the one exported by browsers and used by curl.
+type cookieJar struct {
+	path string
+	jar  *cookiejar.Jar
+	mux  sync.Mutex
+	// Cookies to save by domain, path and name.
+	entries map[string]cookieEntry
+}
+
+// Line of cookies.txt.
+type cookieEntry struct {
+	Domain string
+	// Cookie is sent to the subdomains too.
+	Subdomains bool
+	Path       string
+	Secure     bool
+	// Expiration time, zero for session cookies.
+	Expires time.Time
+	Name    string
+	Value   string
+}
+
+// Load the cookie jar from the file. Missing file means empty jar.
+func loadCookieJar(path string) (*cookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	j := &cookieJar{path: path, jar: jar, entries: make(map[string]cookieEntry)}
+	fh, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	scanner := bufio.NewScanner(fh)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		// curl marks HttpOnly cookies with the prefix.
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%s:%d: invalid cookie line", path, n)
+		}
+		e := cookieEntry{
+			Domain:     fields[0],
+			Subdomains: strings.EqualFold(fields[1], "TRUE"),
+			Path:       fields[2],
+			Secure:     strings.EqualFold(fields[3], "TRUE"),
+			Name:       fields[5],
+			Value:      fields[6],
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid expiration time", path, n)
+		}
+		if expires > 0 {
+			e.Expires = time.Unix(expires, 0)
+			if e.Expires.Before(time.Now()) {
+				continue
+			}
+		}
+		j.add(e)
+	}
+	return j, scanner.Err()
+}
+
+// Add the entry to the jar.
+func (j *cookieJar) add(e cookieEntry) {
+	host := strings.TrimPrefix(e.Domain, ".")
+	scheme := "http"
+	if e.Secure {
+		scheme = "https"
+	}
+	c := &http.Cookie{Name: e.Name, Value: e.Value, Path: e.Path, Secure: e.Secure, Expires: e.Expires}
+	if e.Subdomains {
+		c.Domain = host
+	}
+	j.jar.SetCookies(&url.URL{Scheme: scheme, Host: host, Path: e.Path}, []*http.Cookie{c})
+	j.mux.Lock()
+	j.entries[e.Domain+"\t"+e.Path+"\t"+e.Name] = e
+	j.mux.Unlock()
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	for _, c := range cookies {
+		e := cookieEntry{Domain: u.Hostname(), Path: c.Path, Secure: c.Secure, Name: c.Name, Value: c.Value}
+		if len(c.Domain) > 0 {
+			e.Domain, e.Subdomains = "."+strings.TrimPrefix(c.Domain, "."), true
+		}
+		if len(e.Path) == 0 {
+			e.Path = "/"
+		}
+		switch {
+		case c.MaxAge > 0:
+			e.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			e.Expires = c.Expires
+		}
+		key := e.Domain + "\t" + e.Path + "\t" + e.Name
+		if c.MaxAge < 0 || (!e.Expires.IsZero() && e.Expires.Before(time.Now())) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = e
+	}
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Write the cookies to the file, session cookies are kept too.
+// The data is written to the temporary file first to avoid corrupted jar on failure.
+func (j *cookieJar) Save() error {
+	j.mux.Lock()
+	lines := make([]string, 0, len(j.entries))
+	for _, e := range j.entries {
+		var expires int64
+		if !e.Expires.IsZero() {
+			expires = e.Expires.Unix()
+		}
+		lines = append(lines, strings.Join([]string{
+			e.Domain,
+			strings.ToUpper(strconv.FormatBool(e.Subdomains)),
+			e.Path,
+			strings.ToUpper(strconv.FormatBool(e.Secure)),
+			strconv.FormatInt(expires, 10),
+			e.Name,
+			e.Value,
+		}, "\t"))
+	}
+	j.mux.Unlock()
+	sort.Strings(lines)
+	data := "# Netscape HTTP Cookie File\n" + strings.Join(lines, "\n") + "\n"
+	tmp := j.path + ".tmp"
+	// Cookies may contain the session secrets.
+	if err := os.WriteFile(tmp, []byte(data), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	return durable(j.path)
+}
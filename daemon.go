@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Daemon fetches the feeds periodically and serves the commands of other glsdl invocations
+// via control socket, so they don't run independently.
+type daemon struct {
+	conf     *Config
+	opts     runOptions
+	interval time.Duration
+	trigger  chan struct{}
+
+	mux     sync.Mutex
+	started time.Time
+	running bool
+	current string
+	next    time.Time
+	waiters []chan struct{}
+	feeds   map[string]*feedStatus
+}
+
+// Status of the last run of the feed.
+type feedStatus struct {
+	Run time.Time
+	// Time of the last run without errors.
+	Success    time.Time
+	Downloaded int
+	Failed     int
+	Err        string
+	// Failed runs in a row.
+	Failures int
+	// Publishing date of the newest episode and the usual interval between the episodes.
+	LastEpisode time.Time
+	Cadence     time.Duration
+	// Warning about the feed health, see feedStatus.warning.
+	Warning string
+	dl      *Glsdl
+}
+
+// Request sent to the daemon via control socket.
+type daemonRequest struct {
+	Cmd  string `json:"cmd"`
+	Feed string `json:"feed,omitempty"`
+	// Page of the list command.
+	Page listPage `json:"page"`
+}
+
+// Check if the command is served by the daemon when it runs.
+func daemonCommand(cmd string) bool {
+	return cmd == "" || cmd == "fetch" || cmd == "list" || cmd == "status" || cmd == "pause" || cmd == "resume"
+}
+
+// Get the default path of the control socket.
+func defaultSocketPath() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return dir + ps + "glsdl.sock"
+	}
+	return os.TempDir() + ps + "glsdl-" + strconv.Itoa(os.Getuid()) + ".sock"
+}
+
+// Send the command to the running daemon and copy its response to out.
+// Returns an error if the daemon isn't running.
+func requestDaemon(socket string, req daemonRequest, out io.Writer) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	_, err = io.Copy(out, conn)
+	return err
+}
+
+// Signals stopping and reloading the daemon, the service manager sends them too.
+var daemonSignals = make(chan os.Signal, 1)
+
+// Run the daemon until SIGINT or SIGTERM.
+// Health endpoint is served on httpAddr if set.
+func runDaemon(socket, httpAddr string, interval time.Duration, conf *Config, opts runOptions) error {
+	if conn, err := net.Dial("unix", socket); err == nil {
+		_ = conn.Close()
+		return fmt.Errorf("%s", msg("daemon.exists", socket))
+	}
+	// Remove the socket left by crashed daemon.
+	_ = os.Remove(socket)
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ln.Close()
+	}()
+	log.Println(msg("daemon.listening", socket))
+
+	opts.gate = loadPauseGate(filepath.Dir(*confPath) + ps + PauseFile)
+	d := &daemon{
+		conf:     conf,
+		opts:     opts,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+		started:  time.Now(),
+		feeds:    make(map[string]*feedStatus),
+	}
+	go d.loop()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	if len(httpAddr) > 0 {
+		if err := d.serveHealth(httpAddr); err != nil {
+			return err
+		}
+	}
+	sdNotify("READY=1")
+	go sdWatchdog()
+
+	// Re-read the config on SIGHUP.
+	signal.Notify(daemonSignals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for s := range daemonSignals {
+		if s != syscall.SIGHUP {
+			break
+		}
+		sdNotify("RELOADING=1")
+		if conf, err := loadFeeds("daemon"); err != nil {
+			log.Println(err)
+		} else {
+			d.mux.Lock()
+			d.conf = conf
+			d.mux.Unlock()
+			log.Println(msg("daemon.reloaded", *confPath))
+		}
+		sdNotify("READY=1")
+	}
+	sdNotify("STOPPING=1")
+	return nil
+}
+
+// Run the feeds by the interval or on request.
+func (d *daemon) loop() {
+	for {
+		d.run()
+		d.mux.Lock()
+		d.next = time.Now().Add(d.interval)
+		d.mux.Unlock()
+		select {
+		case <-time.After(d.interval):
+		case <-d.trigger:
+		}
+	}
+}
+
+// Run fetch against all feeds and wake up the waiters.
+func (d *daemon) run() {
+	d.mux.Lock()
+	d.running = true
+	conf := d.conf
+	d.mux.Unlock()
+
+	runs := runFeeds("fetch", conf, d.opts, func(feed *FeedConfig) {
+		d.mux.Lock()
+		d.current = feed.Name
+		d.mux.Unlock()
+	})
+
+	d.mux.Lock()
+	warnings := make([]feedWarning, 0)
+	for _, r := range runs {
+		s := &feedStatus{Run: r.Time, dl: r.DL}
+		prev, ok := d.feeds[r.Feed.Name]
+		if ok {
+			s.Success, s.LastEpisode, s.Cadence = prev.Success, prev.LastEpisode, prev.Cadence
+		}
+		if r.DL != nil {
+			s.Downloaded, s.Failed = r.DL.statDl, r.DL.statFail
+			if r.DL.feed != nil {
+				s.LastEpisode, s.Cadence = feedCadence(r.DL.feed)
+			}
+		}
+		if r.Err != nil {
+			s.Err = r.Err.Error()
+			s.Failures = 1
+			if ok {
+				s.Failures += prev.Failures
+			}
+		} else {
+			s.Success = r.Time
+		}
+		// Warn once when the feed gets unhealthy.
+		s.Warning = s.warning(time.Now())
+		if len(s.Warning) > 0 && (!ok || prev.Warning != s.Warning) {
+			log.Println(msg("feed", r.Feed.Name), s.Warning)
+			warnings = append(warnings, feedWarning{Feed: r.Feed.Name, Text: s.Warning})
+		}
+		d.feeds[r.Feed.Name] = s
+	}
+	d.running, d.current = false, ""
+	waiters := d.waiters
+	d.waiters = nil
+	d.mux.Unlock()
+	for _, w := range waiters {
+		close(w)
+	}
+	if len(warnings) > 0 {
+		sendFeedWarnings(conf.Notify, warnings)
+	}
+}
+
+// Wait for the run to complete. The run in progress is reused, otherwise new one is started.
+func (d *daemon) fetch() {
+	done := make(chan struct{})
+	d.mux.Lock()
+	d.waiters = append(d.waiters, done)
+	running := d.running
+	d.mux.Unlock()
+	if !running {
+		select {
+		case d.trigger <- struct{}{}:
+		default:
+		}
+	}
+	<-done
+}
+
+// Serve the control socket connection.
+func (d *daemon) serve(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+	var req daemonRequest
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+		return
+	}
+	switch req.Cmd {
+	case "", "fetch":
+		if paused, _ := d.opts.gate.state(); paused {
+			_, _ = fmt.Fprintln(conn, msg("daemon.paused"))
+			return
+		}
+		d.fetch()
+		d.report(conn, req.Feed)
+	case "pause", "resume":
+		var err error
+		if req.Cmd == "pause" {
+			err = d.opts.gate.pause()
+		} else {
+			err = d.opts.gate.resume()
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(conn, msg("failed", err))
+			return
+		}
+		_, _ = fmt.Fprintln(conn, msg("daemon."+req.Cmd+"d"))
+	case "list":
+		d.list(conn, req.Feed, req.Page)
+	case "status":
+		d.status(conn, req.Feed)
+	default:
+		_, _ = fmt.Fprintln(conn, msg("unknown_command", req.Cmd))
+	}
+}
+
+// Get the statuses of the feeds filtered by name.
+func (d *daemon) statuses(name string) ([]string, []*feedStatus) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	names, statuses := make([]string, 0), make([]*feedStatus, 0)
+	for _, feed := range d.conf.Feeds {
+		if len(name) > 0 && feed.Name != name {
+			continue
+		}
+		names = append(names, feed.Name)
+		statuses = append(statuses, d.feeds[feed.Name])
+	}
+	return names, statuses
+}
+
+// Write the statistics of the last run.
+func (d *daemon) report(out io.Writer, name string) {
+	names, statuses := d.statuses(name)
+	for i, s := range statuses {
+		_, _ = fmt.Fprintln(out, msg("feed", names[i]))
+		switch {
+		case s == nil:
+		case len(s.Err) > 0:
+			_, _ = fmt.Fprintln(out, "*", msg("failed", s.Err))
+		case s.dl != nil:
+			_, _ = fmt.Fprintln(out, strings.Join(s.dl.Report(), "\n"))
+		}
+	}
+}
+
+// Write the archived episodes known by the last run.
+func (d *daemon) list(out io.Writer, name string, page listPage) {
+	names, statuses := d.statuses(name)
+	for i, s := range statuses {
+		if len(statuses) > 1 {
+			_, _ = fmt.Fprintln(out, msg("feed", names[i]))
+		}
+		if s != nil && s.dl != nil && s.dl.feed != nil {
+			s.dl.List(out, page)
+		}
+	}
+}
+
+// Write the daemon and feeds status.
+func (d *daemon) status(out io.Writer, name string) {
+	d.mux.Lock()
+	if d.running {
+		_, _ = fmt.Fprintln(out, msg("daemon.running", d.current))
+	} else {
+		_, _ = fmt.Fprintln(out, msg("daemon.idle", d.next.Format(time.RFC3339)))
+	}
+	d.mux.Unlock()
+	if paused, pending := d.opts.gate.state(); paused {
+		_, _ = fmt.Fprintln(out, msg("daemon.pausedstatus", pending))
+	}
+	names, statuses := d.statuses(name)
+	for i, s := range statuses {
+		switch {
+		case s == nil:
+			_, _ = fmt.Fprintln(out, "*", msg("daemon.nofeed", names[i]))
+		case len(s.Err) > 0:
+			_, _ = fmt.Fprintln(out, "*", msg("daemon.feederr", names[i], s.Run.Format(time.RFC3339), s.Err))
+		default:
+			_, _ = fmt.Fprintln(out, "*", msg("daemon.feed", names[i], s.Run.Format(time.RFC3339), s.Downloaded, s.Failed))
+		}
+		if s != nil && len(s.Warning) > 0 {
+			_, _ = fmt.Fprintln(out, "  !", s.Warning)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counters of the process served by /debug/vars along with the standard memstats and cmdline.
+var (
+	varDownloads     = expvar.NewInt("downloads")
+	varDownloadBytes = expvar.NewInt("download_bytes")
+	varProcessed     = expvar.NewInt("processed")
+	varFailures      = expvar.NewInt("failures")
+)
+
+func init() {
+	expvar.Publish("workers", expvar.Func(func() any {
+		return workers.snapshot()
+	}))
+}
+
+// Episodes being processed by the workers, shown by /debug/workers.
+var workers = &workerRegistry{active: make(map[*workerState]struct{})}
+
+type workerRegistry struct {
+	mux    sync.Mutex
+	active map[*workerState]struct{}
+}
+
+// State of the worker processing the episode.
+type workerState struct {
+	feed    string
+	episode string
+	started time.Time
+	// Guarded by the registry mutex.
+	stage string
+	url   string
+	bytes atomic.Int64
+}
+
+// Worker state snapshot.
+type workerInfo struct {
+	Feed    string        `json:"feed"`
+	Episode string        `json:"episode"`
+	Stage   string        `json:"stage"`
+	URL     string        `json:"url,omitempty"`
+	Bytes   int64         `json:"bytes"`
+	Started time.Time     `json:"started"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Register the worker starting the episode.
+func (r *workerRegistry) start(feed, episode string) *workerState {
+	w := &workerState{feed: feed, episode: episode, started: time.Now(), stage: "preparing"}
+	r.mux.Lock()
+	r.active[w] = struct{}{}
+	r.mux.Unlock()
+	return w
+}
+
+// Unregister the worker done with the episode.
+func (r *workerRegistry) done(w *workerState) {
+	r.mux.Lock()
+	delete(r.active, w)
+	r.mux.Unlock()
+}
+
+// Set the current stage of the worker, like downloading or tagging.
+func (r *workerRegistry) setStage(w *workerState, stage, url string) {
+	r.mux.Lock()
+	w.stage, w.url = stage, url
+	r.mux.Unlock()
+}
+
+// Get the worker downloading the URL.
+func (r *workerRegistry) byURL(url string) *workerState {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for w := range r.active {
+		if w.url == url {
+			return w
+		}
+	}
+	return nil
+}
+
+// Get the states of the workers, the longest running ones first.
+func (r *workerRegistry) snapshot() []workerInfo {
+	r.mux.Lock()
+	result := make([]workerInfo, 0, len(r.active))
+	for w := range r.active {
+		result = append(result, workerInfo{
+			Feed:    w.feed,
+			Episode: w.episode,
+			Stage:   w.stage,
+			URL:     w.url,
+			Bytes:   w.bytes.Load(),
+			Started: w.started,
+			Elapsed: time.Since(w.started),
+		})
+	}
+	r.mux.Unlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Started.Before(result[j].Started)
+	})
+	return result
+}
+
+// Counts the downloaded bytes of the worker and the process.
+type workerReader struct {
+	r io.Reader
+	w *workerState
+}
+
+func (r workerReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	if r.w != nil {
+		r.w.bytes.Add(int64(n))
+	}
+	varDownloadBytes.Add(int64(n))
+	return n, err
+}
+
+// Start serving /debug/vars and /debug/workers on the address, and /debug/pprof/ profiles if requested.
+func serveDebug(addr string, profiling bool) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	mux.HandleFunc("/debug/workers", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(workers.snapshot())
+	})
+	if profiling {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Println(err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Checksums of the downloaded files of all feeds, finds the episodes cross-posted to several feeds.
+// The feeds tag the same file differently, so the duplicate isn't linked to the original one,
+// its state DB record references the original file instead.
+type dedupIndex struct {
+	mux   sync.Mutex
+	files map[string]string
+}
+
+// Create the index of the files recorded in the state DBs of the feeds.
+func newDedupIndex(feeds []*FeedConfig) *dedupIndex {
+	idx := &dedupIndex{files: make(map[string]string)}
+	for _, feed := range feeds {
+		s, err := LoadState(feed.dir + ps + StateFile)
+		if err != nil {
+			continue
+		}
+		for _, e := range s.Episodes {
+			if len(e.Checksum) > 0 && len(e.Duplicate) == 0 && len(e.Filename) > 0 {
+				idx.files[e.Checksum] = feed.dir + ps + e.Filename
+			}
+		}
+	}
+	return idx
+}
+
+// Get the existing file of another feed with the same checksum, otherwise remember the file as the original one.
+func (idx *dedupIndex) claim(sum, filename string) (string, bool) {
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	if original, ok := idx.files[sum]; ok && filepath.Dir(original) != filepath.Dir(filename) {
+		if _, err := os.Stat(original); err == nil {
+			return original, true
+		}
+	}
+	idx.files[sum] = filename
+	return "", false
+}
+
+// Get SHA-256 checksum of the file.
+func fileChecksum(filename string) (string, error) {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = fh.Close()
+	}()
+	h := sha256.New()
+	if _, err := io.Copy(h, fh); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Check if the episode references the existing file of another feed.
+func (e Episode) duplicated() bool {
+	if len(e.Duplicate) == 0 {
+		return false
+	}
+	_, err := os.Stat(e.Duplicate)
+	return err == nil
+}
+
+// Record the downloaded episode found in another feed, the downloaded copy is removed.
+func (dl *Glsdl) recordDuplicate(key string, item *gofeed.Item, enclosure *gofeed.Enclosure, title, original string, remote remoteInfo) {
+	dl.recordDownload(key, item, enclosure, title, "", true, remote)
+	e, _ := dl.state.Get(key)
+	e.Duplicate = original
+	dl.state.Put(key, e)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddr        = "239.255.255.250:1900"
+	ssdpTimeout     = 3 * time.Second
+	mediaRendererST = "urn:schemas-upnp-org:device:MediaRenderer:1"
+	avTransportType = "urn:schemas-upnp-org:service:AVTransport:1"
+)
+
+// UPnP device description.
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	FriendlyName string        `xml:"friendlyName"`
+	Services     []upnpService `xml:"serviceList>service"`
+	Devices      []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// DLNA media renderer controlled by the AVTransport service.
+type dlnaRenderer struct {
+	name       string
+	host       string
+	controlURL string
+}
+
+// Discover DLNA media renderers via SSDP and pick the one which name contains the given string.
+func findDLNARenderer(name string) (*dlnaRenderer, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	dst, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + mediaRendererST + "\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(req), dst); err != nil {
+		return nil, err
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(ssdpTimeout))
+	seen := make(map[string]bool)
+	found := make([]string, 0)
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		location := resp.Header.Get("Location")
+		if len(location) == 0 || seen[location] {
+			continue
+		}
+		seen[location] = true
+		r, err := describeDLNARenderer(location)
+		if err != nil {
+			continue
+		}
+		if len(name) == 0 || strings.Contains(strings.ToLower(r.name), strings.ToLower(name)) {
+			return r, nil
+		}
+		found = append(found, r.name)
+	}
+	if len(found) > 0 {
+		return nil, fmt.Errorf("DLNA renderer %q not found, available: %s", name, strings.Join(found, ", "))
+	}
+	return nil, fmt.Errorf("no DLNA renderers found")
+}
+
+// Fetch the device description and find AVTransport control URL.
+func describeDLNARenderer(location string) (*dlnaRenderer, error) {
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var root upnpRoot
+	if err := xml.NewDecoder(resp.Body).Decode(&root); err != nil {
+		return nil, err
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if len(root.URLBase) > 0 {
+		if u, err := url.Parse(root.URLBase); err == nil {
+			base = u
+		}
+	}
+	var find func(d upnpDevice) (*dlnaRenderer, bool)
+	find = func(d upnpDevice) (*dlnaRenderer, bool) {
+		for _, s := range d.Services {
+			if s.ServiceType == avTransportType {
+				control, err := base.Parse(s.ControlURL)
+				if err != nil {
+					return nil, false
+				}
+				return &dlnaRenderer{name: d.FriendlyName, host: base.Hostname(), controlURL: control.String()}, true
+			}
+		}
+		for _, sub := range d.Devices {
+			if r, ok := find(sub); ok {
+				return r, true
+			}
+		}
+		return nil, false
+	}
+	if r, ok := find(root.Device); ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%s: no AVTransport service", location)
+}
+
+func (r *dlnaRenderer) Host() string { return r.host }
+func (r *dlnaRenderer) Name() string { return r.name }
+
+func (r *dlnaRenderer) Play(mediaURL, title, artist string) error {
+	didl := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" ` +
+		`xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		`<item id="0" parentID="-1" restricted="1">` +
+		`<dc:title>` + xmlEscape(title) + `</dc:title>` +
+		`<dc:creator>` + xmlEscape(artist) + `</dc:creator>` +
+		`<upnp:class>object.item.audioItem.musicTrack</upnp:class>` +
+		`<res protocolInfo="http-get:*:audio/mpeg:*">` + xmlEscape(mediaURL) + `</res>` +
+		`</item></DIDL-Lite>`
+	if err := r.action("SetAVTransportURI", "<InstanceID>0</InstanceID>"+
+		"<CurrentURI>"+xmlEscape(mediaURL)+"</CurrentURI>"+
+		"<CurrentURIMetaData>"+xmlEscape(didl)+"</CurrentURIMetaData>"); err != nil {
+		return err
+	}
+	return r.action("Play", "<InstanceID>0</InstanceID><Speed>1</Speed>")
+}
+
+func (r *dlnaRenderer) Stop() error {
+	return r.action("Stop", "<InstanceID>0</InstanceID>")
+}
+
+// Call the AVTransport action via SOAP.
+func (r *dlnaRenderer) action(name, args string) error {
+	body := `<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+		`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>` +
+		`<u:` + name + ` xmlns:u="` + avTransportType + `">` + args + `</u:` + name + `>` +
+		`</s:Body></s:Envelope>`
+	req, err := http.NewRequest(http.MethodPost, r.controlURL, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+avTransportType+"#"+name+`"`)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("DLNA %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Escape the text for XML.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeout of one DNS query.
+const dnsTimeout = 10 * time.Second
+
+// Create the resolver sending the queries to DNS-over-HTTPS server (https://host/dns-query)
+// or DNS-over-TLS server (tls://host[:port]).
+func newResolver(server string) (*net.Resolver, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "https":
+		// The DoH server itself is resolved with the system resolver.
+		client := &http.Client{Timeout: dnsTimeout, Transport: &http.Transport{ForceAttemptHTTP2: true}}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return &dohConn{ctx: ctx, url: server, client: client}, nil
+			},
+		}, nil
+	case "tls":
+		address := u.Host
+		if len(u.Port()) == 0 {
+			address = net.JoinHostPort(u.Hostname(), "853")
+		}
+		dialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Timeout: dnsTimeout},
+			Config:    &tls.Config{ServerName: u.Hostname()},
+		}
+		// Stream connection makes the resolver use TCP framing of the messages, as DoT does.
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "tcp", address)
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported DNS server %s, https:// or tls:// expected", server)
+}
+
+// Connection sending each written DNS message as DoH request and reading the answer from the response.
+// It pretends to be a packet connection, so the resolver writes and reads whole messages.
+type dohConn struct {
+	ctx      context.Context
+	url      string
+	client   *http.Client
+	mux      sync.Mutex
+	answer   bytes.Buffer
+	deadline time.Time
+}
+
+func (c *dohConn) Write(b []byte) (int, error) {
+	ctx := c.ctx
+	c.mux.Lock()
+	deadline := c.deadline
+	c.mux.Unlock()
+	if !deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, deadline)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return 0, &httpStatusError{URL: c.url, Code: resp.StatusCode, Status: resp.Status}
+	}
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return 0, err
+	}
+	c.mux.Lock()
+	c.answer.Write(answer)
+	c.mux.Unlock()
+	return len(b), nil
+}
+
+func (c *dohConn) Read(b []byte) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.answer.Len() == 0 {
+		return 0, errors.New("doh: no answer")
+	}
+	// The whole message is read at once, like a datagram.
+	n := copy(b, c.answer.Bytes())
+	c.answer.Reset()
+	return n, nil
+}
+
+func (c *dohConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *dohConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+func (c *dohConn) SetDeadline(t time.Time) error {
+	c.mux.Lock()
+	c.deadline = t
+	c.mux.Unlock()
+	return nil
+}
+
+func (c *dohConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *dohConn) SetWriteDeadline(t time.Time) error { return c.SetDeadline(t) }
+func (c *dohConn) Close() error                       { return nil }
+func (c *dohConn) LocalAddr() net.Addr                { return dohAddr("local") }
+func (c *dohConn) RemoteAddr() net.Addr               { return dohAddr(strings.TrimPrefix(c.url, "https://")) }
+
+// Address of DoH connection.
+type dohAddr string
+
+func (a dohAddr) Network() string { return "doh" }
+func (a dohAddr) String() string  { return string(a) }
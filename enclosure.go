@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Get the media file of the item.
+// RSS items have one enclosure, but JSON Feed items may have several attachments: the artwork,
+// the same episode in different formats, etc. MP3 is preferred, then any audio file.
+func itemEnclosure(item *gofeed.Item) (*gofeed.Enclosure, bool) {
+	var audio, first *gofeed.Enclosure
+	for _, e := range item.Enclosures {
+		if e == nil || len(e.URL) == 0 {
+			continue
+		}
+		if first == nil {
+			first = e
+		}
+		mime := strings.ToLower(e.Type)
+		if mime == "audio/mpeg" || mime == "audio/mp3" || (len(mime) == 0 && isMP3URL(e.URL)) {
+			return e, true
+		}
+		if audio == nil && strings.HasPrefix(mime, "audio/") {
+			audio = e
+		}
+	}
+	if audio != nil {
+		return audio, true
+	}
+	if first == nil {
+		return youtubeEnclosure(item)
+	}
+	return first, true
+}
+
+// Check if the URL points to MP3 file.
+func isMP3URL(rawURL string) bool {
+	if i := strings.IndexAny(rawURL, "?#"); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	return strings.EqualFold(path.Ext(rawURL), ".mp3")
+}
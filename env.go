@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Prefix of the environment variables overriding the settings.
+const EnvPrefix = "GLSDL_"
+
+// Env var names of the flags which differ from the upper-cased flag name.
+var envNames = map[string]string{
+	"t": "THREADS",
+	"4": "IPV4",
+	"6": "IPV6",
+}
+
+// Get the env var name of the flag, like GLSDL_NO_COLOR for -no-color.
+func envName(flagName string) string {
+	name, ok := envNames[flagName]
+	if !ok {
+		name = strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+	}
+	return EnvPrefix + name
+}
+
+// Set the flags missing in the command line from the env vars.
+// So the flags take precedence over the env vars, and the env vars over the config.
+func applyEnv() error {
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || err != nil || flagSet(f.Name) {
+			return
+		}
+		if serr := flag.Set(f.Name, value); serr != nil {
+			err = fmt.Errorf("%s: %w", envName(f.Name), serr)
+		}
+	})
+	return err
+}
+
+// Parse the feeds of GLSDL_FEEDS env var: space or comma separated entries like name=url.
+// The host of URL is used as the name if it's omitted.
+func parseEnvFeeds(value string) ([]*FeedConfig, error) {
+	feeds := make([]*FeedConfig, 0)
+	entries := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n' || r == '\t'
+	})
+	for _, entry := range entries {
+		name, rawURL := "", entry
+		if i := strings.Index(entry, "="); i > 0 && !strings.Contains(entry[:i], "://") {
+			name, rawURL = entry[:i], entry[i+1:]
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || len(u.Host) == 0 {
+			return nil, fmt.Errorf("%sFEEDS: invalid feed URL %q", EnvPrefix, rawURL)
+		}
+		if len(name) == 0 {
+			name = u.Host
+		}
+		feeds = append(feeds, &FeedConfig{Name: name, URL: rawURL})
+	}
+	return feeds, nil
+}
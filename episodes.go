@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Find the archived episode by its number, GUID or "latest" for the newest one.
+func (dl *Glsdl) findEpisode(query string) (archivedItem, error) {
+	if _, err := dl.parseFeed(); err != nil {
+		return archivedItem{}, err
+	}
+	archived := dl.archivedItems()
+	if len(archived) == 0 {
+		return archivedItem{}, fmt.Errorf("no archived episodes of %s", dl.conf.Name)
+	}
+	if len(query) == 0 || query == "latest" {
+		return archived[len(archived)-1], nil
+	}
+	for _, a := range archived {
+		if dl.itemMatches(a.Item, query) {
+			return a, nil
+		}
+	}
+	return archivedItem{}, fmt.Errorf("episode %s of %s not found", query, dl.conf.Name)
+}
+
+// Check if the item has the GUID or the episode number, leading zeros are ignored.
+func (dl *Glsdl) itemMatches(item *gofeed.Item, query string) bool {
+	prefix, _ := dl.parseTitle(item)
+	return item.GUID == query || strings.EqualFold(prefix, query) ||
+		(len(prefix) > 0 && strings.TrimLeft(prefix, "0") == strings.TrimLeft(query, "0"))
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+)
+
+// Error categories, check them with errors.Is.
+var (
+	ErrNetwork    = errors.New("network error")
+	ErrHTTPStatus = errors.New("http status error")
+	ErrDisk       = errors.New("disk error")
+	ErrTagging    = errors.New("tagging error")
+	ErrParse      = errors.New("parse error")
+)
+
+// Categories with their names and exit codes, the most severe first.
+var errorCategories = []struct {
+	err  error
+	name string
+	code int
+}{
+	{ErrDisk, "disk", 5},
+	{ErrParse, "parse", 7},
+	{ErrTagging, "tagging", 6},
+	{ErrHTTPStatus, "http", 4},
+	{ErrNetwork, "network", 3},
+}
+
+// Error with the category.
+type classifiedError struct {
+	category error
+	err      error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.category, e.err}
+}
+
+// Add the category to the error. The category is detected by the error type if possible:
+// HTTP status, network and file system errors, otherwise the fallback category is used.
+func classify(err, fallback error) error {
+	if err == nil {
+		return nil
+	}
+	for _, c := range errorCategories {
+		if errors.Is(err, c.err) {
+			return err
+		}
+	}
+	var (
+		statusErr *httpStatusError
+		netErr    net.Error
+		pathErr   *fs.PathError
+		linkErr   *os.LinkError
+	)
+	category := fallback
+	switch {
+	case errors.As(err, &statusErr):
+		category = ErrHTTPStatus
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF):
+		category = ErrNetwork
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		category = ErrDisk
+	}
+	if category == nil {
+		return err
+	}
+	return &classifiedError{category: category, err: err}
+}
+
+// Get the name of the error category, empty if the error isn't classified.
+func errorCategory(err error) string {
+	for _, c := range errorCategories {
+		if errors.Is(err, c.err) {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// Get the exit code of the most severe error, 1 for unclassified errors and 0 if there are no errors.
+func exitCode(errs []error) int {
+	code, severity := 0, len(errorCategories)+1
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		s, c := len(errorCategories), 1
+		for i, cat := range errorCategories {
+			if errors.Is(err, cat.err) {
+				s, c = i, cat.code
+				break
+			}
+		}
+		if s < severity {
+			severity, code = s, c
+		}
+	}
+	return code
+}
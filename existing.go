@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Record the episodes downloaded before, by another tool or by hand, in the state DB, so they aren't
+// downloaded again. The media files of the directory (the download directory by default) are matched
+// to the feed items by the title tag first, then by the episode number and title words in the name.
+// Files of another directory are moved to the download directory and named by the template.
+func (dl *Glsdl) ImportExisting(dir string, out io.Writer) (err error) {
+	feed, err := dl.parseFeed()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if serr := dl.state.Save(); serr != nil && err == nil {
+			err = serr
+		}
+	}()
+	if len(dir) == 0 {
+		dir = dl.downloadDir
+	}
+	dir = filepath.Clean(dir)
+	external := dir != filepath.Clean(dl.downloadDir)
+
+	// Items without the file yet.
+	pending := make([]*gofeed.Item, 0)
+	for _, item := range feed.Items {
+		if e, ok := dl.state.Get(itemKey(item)); ok && (e.Deleted || e.duplicated() || dl.fileExists(e.Filename)) {
+			continue
+		}
+		if _, ok := dl.itemMedia(item); ok {
+			pending = append(pending, item)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	imported := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || isLatestFile(name) || (!strings.EqualFold(filepath.Ext(name), ".mp3") && !isContainerFile(name)) {
+			continue
+		}
+		if !external && dl.state.HasFilename(name) {
+			continue
+		}
+		i := dl.matchExisting(pending, dir+ps+name)
+		if i < 0 {
+			_, _ = fmt.Fprintln(out, "*", msg("existing.nomatch", name))
+			continue
+		}
+		item := pending[i]
+		filename, finalTitle := dl.itemFilename(item)
+		if external {
+			// Keep the extension of the file, it may differ from the feed one.
+			filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + filepath.Ext(name)
+			if _, err := os.Stat(filename); err == nil {
+				_, _ = fmt.Fprintln(out, "*", msg("migrate.skipped", name, dl.relName(filename)))
+				continue
+			}
+			if err := moveFile(dir+ps+name, filename); err != nil {
+				return err
+			}
+		} else {
+			filename = dl.downloadDir + ps + name
+		}
+		m, _ := dl.itemMedia(item)
+		dl.recordDownload(itemKey(item), item, m.Enclosure, finalTitle, dl.relName(filename), false, remoteInfo{})
+		_, _ = fmt.Fprintln(out, "*", name, "->", finalTitle)
+		pending = append(pending[:i], pending[i+1:]...)
+		imported++
+	}
+	_, _ = fmt.Fprintln(out, msg("existing.imported", imported))
+
+	return nil
+}
+
+// Find the item of the existing file: the title tag equal to the item or final title wins,
+// otherwise the episode number and most of the title words should be found in the name.
+// Returns the index of the item or -1.
+func (dl *Glsdl) matchExisting(items []*gofeed.Item, filename string) int {
+	if strings.EqualFold(filepath.Ext(filename), ".mp3") {
+		if tag, err := ReadID3(filename); err == nil {
+			if title := strings.TrimSpace(tag.Text("TIT2")); len(title) > 0 {
+				for i, item := range items {
+					_, finalTitle := dl.itemFilename(item)
+					if strings.EqualFold(title, finalTitle) || strings.EqualFold(title, strings.TrimSpace(item.Title)) {
+						return i
+					}
+				}
+			}
+		}
+	}
+
+	base := filepath.Base(filename)
+	words := normalizeWords(strings.TrimSuffix(base, filepath.Ext(base)))
+	best, bestScore := -1, 0.0
+	for i, item := range items {
+		prefix, title := dl.parseTitle(item)
+		number := strings.TrimLeft(strings.ToLower(prefix), "0")
+		threshold := fuzzyThreshold
+		if len(number) == 0 {
+			threshold = fuzzyThresholdNoNumber
+		}
+		score := fuzzyScore(words, number, normalizeWords(title))
+		if score >= threshold && score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// Move the file, copying it to another file system.
+func moveFile(src, dest string) error {
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+	if err := copyFile(src, dest); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Name of the manifest in the export archive.
+const ManifestFile = "manifest.json"
+
+// Manifest of the export archive: the feeds and their state DB records.
+// The files of each feed are kept in the directory named after the feed.
+type manifest struct {
+	Version int            `json:"version"`
+	Created time.Time      `json:"created"`
+	Feeds   []manifestFeed `json:"feeds"`
+}
+
+// Feed of the export archive.
+type manifestFeed struct {
+	Name     string              `json:"name"`
+	URL      string              `json:"url"`
+	Dir      string              `json:"dir"`
+	Episodes map[string]*Episode `json:"episodes"`
+}
+
+// Check if the path is tar archive, compressed with gzip if it ends with .gz or .tgz.
+func isTarPath(p string) bool {
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(strings.ToLower(p), ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Export the feeds to the archive (path ending with .tar, .tar.gz or .tgz) or directory:
+// the episodes, sidecar metadata and covers of each feed in its directory and the manifest.
+func Export(conf *Config, dest string, out io.Writer) error {
+	m := manifest{Version: 1, Created: time.Now().UTC()}
+	for _, feed := range conf.Feeds {
+		state, err := LoadState(feed.dir + ps + StateFile)
+		if err != nil {
+			return err
+		}
+		m.Feeds = append(m.Feeds, manifestFeed{Name: feed.Name, URL: feed.URL, Dir: sanitizeName(feed.Name), Episodes: state.Episodes})
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var w archiveWriter
+	if isTarPath(dest) {
+		tw, err := newTarWriter(dest)
+		if err != nil {
+			return err
+		}
+		w = tw
+	} else {
+		w = dirWriter(dest)
+	}
+	// The manifest goes first, so import knows the feeds before the files.
+	err = w.write(ManifestFile, int64(len(data)), time.Now(), strings.NewReader(string(data)))
+	for i := 0; err == nil && i < len(conf.Feeds); i++ {
+		feed := conf.Feeds[i]
+		err = filepath.WalkDir(feed.dir, func(p string, d fs.DirEntry, err error) error {
+			// The feed wasn't fetched yet, nothing to export but the manifest.
+			if p == feed.dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			name := d.Name()
+			// The state DB is restored from the manifest.
+			if name == StateFile || name == LockFile || strings.HasSuffix(name, ".tmp") {
+				return nil
+			}
+			rel, err := filepath.Rel(feed.dir, p)
+			if err != nil {
+				return err
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			fh, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = fh.Close()
+			}()
+			return w.write(m.Feeds[i].Dir+"/"+filepath.ToSlash(rel), fi.Size(), fi.ModTime(), fh)
+		})
+		if err == nil {
+			_, _ = fmt.Fprintln(out, "*", msg("export.feed", feed.Name, len(m.Feeds[i].Episodes)))
+		}
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Import the archive made by export: the files are placed to the download directories of the configured
+// feeds (or the new directories named after the feeds) and the records are added to their state DBs.
+// Existing files and records are kept.
+func Import(conf *Config, src string, out io.Writer) error {
+	var r archiveReader
+	if isTarPath(src) {
+		tr, err := newTarReader(src)
+		if err != nil {
+			return err
+		}
+		r = tr
+	} else {
+		r = newDirReader(src)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	var m *manifest
+	dirs := make(map[string]string)
+	err := r.each(func(name string, modified time.Time, body io.Reader) error {
+		if name == ManifestFile {
+			m = &manifest{}
+			if err := json.NewDecoder(body).Decode(m); err != nil {
+				return fmt.Errorf("%s: %w", ManifestFile, err)
+			}
+			for _, mf := range m.Feeds {
+				dirs[mf.Dir] = importDir(conf, mf)
+			}
+			return nil
+		}
+		if m == nil {
+			return errors.New(msg("import.nomanifest"))
+		}
+		clean := path.Clean(name)
+		feedDir, rel, ok := strings.Cut(clean, "/")
+		root, known := dirs[feedDir]
+		if !ok || !known || strings.HasPrefix(clean, "../") || path.IsAbs(clean) {
+			return fmt.Errorf("%s: unexpected file in archive", name)
+		}
+		dest := filepath.Join(root, filepath.FromSlash(rel))
+		if _, err := os.Stat(dest); err == nil {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		fh, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(fh, body); err != nil {
+			_ = fh.Close()
+			_ = os.Remove(dest)
+			return err
+		}
+		if err := fh.Close(); err != nil {
+			return err
+		}
+		return os.Chtimes(dest, modified, modified)
+	})
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return errors.New(msg("import.nomanifest"))
+	}
+
+	for _, mf := range m.Feeds {
+		dir := dirs[mf.Dir]
+		state, err := LoadState(dir + ps + StateFile)
+		if err != nil {
+			return err
+		}
+		added := 0
+		for key, e := range mf.Episodes {
+			if _, ok := state.Get(key); !ok {
+				state.mux.Lock()
+				state.Episodes[key] = e
+				state.mux.Unlock()
+				added++
+			}
+		}
+		if err := state.Save(); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, "*", msg("import.feed", mf.Name, added, dir))
+		if _, ok := conf.feedByName(mf.Name); !ok {
+			_, _ = fmt.Fprintln(out, "  ", msg("import.unknown", mf.URL))
+		}
+	}
+	return nil
+}
+
+// Get the download directory of the imported feed: the configured feed with the same name or URL,
+// or the new directory in the root one.
+func importDir(conf *Config, mf manifestFeed) string {
+	for _, feed := range conf.Feeds {
+		if feed.Name == mf.Name || feed.URL == mf.URL {
+			return feed.dir
+		}
+	}
+	root := conf.Dir
+	if len(root) == 0 {
+		root = DefaultDir()
+	}
+	return expandHome(root) + ps + sanitizeName(mf.Name)
+}
+
+// Get the configured feed by the name.
+func (c *Config) feedByName(name string) (*FeedConfig, bool) {
+	for _, feed := range c.Feeds {
+		if feed.Name == name {
+			return feed, true
+		}
+	}
+	return nil, false
+}
+
+// Writer of the export archive.
+type archiveWriter interface {
+	write(name string, size int64, modified time.Time, body io.Reader) error
+	Close() error
+}
+
+// Reader of the export archive, the files are passed to the function in order.
+type archiveReader interface {
+	each(fn func(name string, modified time.Time, body io.Reader) error) error
+	Close() error
+}
+
+// Tar archive, compressed with gzip by the extension.
+type tarWriter struct {
+	fh *os.File
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func newTarWriter(dest string) (*tarWriter, error) {
+	fh, err := os.Create(dest)
+	if err != nil {
+		return nil, err
+	}
+	w := &tarWriter{fh: fh}
+	var out io.Writer = fh
+	if !strings.HasSuffix(strings.ToLower(dest), ".tar") {
+		w.gz = gzip.NewWriter(fh)
+		out = w.gz
+	}
+	w.tw = tar.NewWriter(out)
+	return w, nil
+}
+
+func (w *tarWriter) write(name string, size int64, modified time.Time, body io.Reader) error {
+	if err := w.tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size, ModTime: modified, Typeflag: tar.TypeReg}); err != nil {
+		return err
+	}
+	_, err := io.Copy(w.tw, body)
+	return err
+}
+
+func (w *tarWriter) Close() error {
+	err := w.tw.Close()
+	if w.gz != nil {
+		if gerr := w.gz.Close(); err == nil {
+			err = gerr
+		}
+	}
+	if ferr := w.fh.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+type tarReader struct {
+	fh *os.File
+	gz *gzip.Reader
+	tr *tar.Reader
+}
+
+func newTarReader(src string) (*tarReader, error) {
+	fh, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	r := &tarReader{fh: fh}
+	var in io.Reader = fh
+	if !strings.HasSuffix(strings.ToLower(src), ".tar") {
+		if r.gz, err = gzip.NewReader(fh); err != nil {
+			_ = fh.Close()
+			return nil, err
+		}
+		in = r.gz
+	}
+	r.tr = tar.NewReader(in)
+	return r, nil
+}
+
+func (r *tarReader) each(fn func(name string, modified time.Time, body io.Reader) error) error {
+	for {
+		h, err := r.tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(h.Name, h.ModTime, r.tr); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *tarReader) Close() error {
+	if r.gz != nil {
+		_ = r.gz.Close()
+	}
+	return r.fh.Close()
+}
+
+// Directory with the same layout as the archive.
+type dirWriter string
+
+func (d dirWriter) write(name string, _ int64, modified time.Time, body io.Reader) error {
+	dest := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	fh, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(fh, body); err != nil {
+		_ = fh.Close()
+		return err
+	}
+	if err := fh.Close(); err != nil {
+		return err
+	}
+	return os.Chtimes(dest, modified, modified)
+}
+
+func (d dirWriter) Close() error {
+	return nil
+}
+
+type dirReader struct {
+	root string
+}
+
+func newDirReader(root string) *dirReader {
+	return &dirReader{root: root}
+}
+
+func (d *dirReader) each(fn func(name string, modified time.Time, body io.Reader) error) error {
+	open := func(name string) error {
+		p := filepath.Join(d.root, filepath.FromSlash(name))
+		fi, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		fh, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = fh.Close()
+		}()
+		return fn(name, fi.ModTime(), fh)
+	}
+	// The manifest goes first, like in the archive.
+	if err := open(ManifestFile); err != nil {
+		return err
+	}
+	return filepath.WalkDir(d.root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(d.root, p)
+		if err != nil || rel == ManifestFile {
+			return err
+		}
+		return open(filepath.ToSlash(rel))
+	})
+}
+
+func (d *dirReader) Close() error {
+	return nil
+}
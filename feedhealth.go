@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Warning about the feed failing or gone silent.
+type feedWarning struct {
+	Feed string
+	Text string
+}
+
+// Get the publishing date of the newest episode and the usual interval between the episodes:
+// the median of the intervals between the last ten. The interval is zero for less than three dated episodes.
+func feedCadence(feed *gofeed.Feed) (last time.Time, cadence time.Duration) {
+	dates := make([]time.Time, 0, len(feed.Items))
+	for _, item := range chronologicalItems(feed) {
+		if published := itemPublished(item); !published.IsZero() {
+			dates = append(dates, published)
+		}
+	}
+	if len(dates) == 0 {
+		return
+	}
+	last = dates[len(dates)-1]
+	if len(dates) < 3 {
+		return
+	}
+	if len(dates) > 11 {
+		dates = dates[len(dates)-11:]
+	}
+	intervals := make([]time.Duration, 0, len(dates)-1)
+	for i := 1; i < len(dates); i++ {
+		intervals = append(intervals, dates[i].Sub(dates[i-1]))
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+	return last, intervals[len(intervals)/2]
+}
+
+// Get the warning about the feed failed -health-failures daemon runs in a row or without new episodes
+// for -dormant times its usual interval. Empty if the feed is fine.
+func (s *feedStatus) warning(now time.Time) string {
+	if *failWarn > 0 && s.Failures >= *failWarn {
+		return msg("health.failing", s.Failures, s.Err)
+	}
+	if *dormant > 0 && s.Cadence > 0 && now.Sub(s.LastEpisode) > time.Duration(*dormant*float64(s.Cadence)) {
+		return msg("health.dormant", formatDays(now.Sub(s.LastEpisode)), formatDays(s.Cadence))
+	}
+	return ""
+}
+
+// Format the duration as the number of days, at least one.
+func formatDays(d time.Duration) int {
+	return max(int((d+12*time.Hour)/(24*time.Hour)), 1)
+}
+
+// Send the warnings to the notifiers right away, regardless of their digest modes.
+func sendFeedWarnings(confs []*NotifierConfig, warnings []feedWarning) {
+	for _, conf := range confs {
+		n, err := newNotifier(conf)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		for _, w := range warnings {
+			note := notification{Topic: conf.topic(w.Feed), Title: msg("notify.warning", w.Feed), Text: w.Text, Episodes: []episodeNote{}}
+			if err := n.send(note); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Reasons of skipping the items, described by skip.<reason> messages.
+const (
+	SkipNoMedia   = "no-media"
+	SkipTitle     = "title"
+	SkipType      = "episode-type"
+	SkipKeyword   = "keyword"
+	SkipDuration  = "duration"
+	SkipDeleted   = "deleted"
+	SkipDuplicate = "dup"
+	SkipQuota     = "quota"
+)
+
+// Check if the item passes the title patterns, episode types and keywords of the feed and the duration
+// limits, see -min-duration and -max-duration flags.
+func (dl *Glsdl) wanted(item *gofeed.Item) bool {
+	return len(dl.filterReason(item)) == 0
+}
+
+// Get the reason of filtering the item out, empty if it's wanted. The items of unknown duration aren't
+// filtered by it. The episode requested by get command is always wanted.
+func (dl *Glsdl) filterReason(item *gofeed.Item) string {
+	if item == dl.requested {
+		return ""
+	}
+	switch {
+	case !dl.conf.match(item.Title):
+		return SkipTitle
+	case !dl.conf.matchType(item):
+		return SkipType
+	case dl.conf.skipped(item):
+		return SkipKeyword
+	}
+	if dl.minDuration > 0 || dl.maxDuration > 0 {
+		if d, ok := itemDuration(item); ok && (d < dl.minDuration || dl.maxDuration > 0 && d > dl.maxDuration) {
+			return SkipDuration
+		}
+	}
+	return ""
+}
+
+// Get the itunes:duration of the item.
+func itemDuration(item *gofeed.Item) (time.Duration, bool) {
+	if item.ITunesExt == nil {
+		return 0, false
+	}
+	return parseITunesDuration(item.ITunesExt.Duration)
+}
+
+// Parse itunes:duration value: seconds or [[HH:]MM:]SS with optional fraction of the seconds.
+func parseITunesDuration(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return 0, false
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	var seconds float64
+	for i, part := range parts {
+		var value float64
+		var err error
+		if i == len(parts)-1 {
+			value, err = strconv.ParseFloat(part, 64)
+		} else {
+			var n int
+			n, err = strconv.Atoi(part)
+			value = float64(n)
+		}
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// Check if itunes:episodeType of the item is one of the downloaded types.
+func (f *FeedConfig) matchType(item *gofeed.Item) bool {
+	if len(f.EpisodeTypes) == 0 {
+		return true
+	}
+	episodeType := "full"
+	if item.ITunesExt != nil && len(strings.TrimSpace(item.ITunesExt.EpisodeType)) > 0 {
+		episodeType = strings.TrimSpace(item.ITunesExt.EpisodeType)
+	}
+	for _, t := range f.EpisodeTypes {
+		if strings.EqualFold(t, episodeType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the item has any of the skipped iTunes keywords or categories.
+func (f *FeedConfig) skipped(item *gofeed.Item) bool {
+	if len(f.SkipKeywords) == 0 {
+		return false
+	}
+	words := append([]string(nil), item.Categories...)
+	if item.ITunesExt != nil {
+		words = append(words, strings.Split(item.ITunesExt.Keywords, ",")...)
+	}
+	for _, word := range words {
+		for _, skip := range f.SkipKeywords {
+			if strings.EqualFold(strings.TrimSpace(word), strings.TrimSpace(skip)) {
+				return true
+			}
+		}
+	}
+	return false
+}
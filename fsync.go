@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// Flush the file and its directory entry to the storage if -fsync flag is set,
+// so the file written or renamed survives the power loss (NAS, USB drives).
+func durable(path string) error {
+	if !*fsync {
+		return nil
+	}
+	if err := syncPath(path); err != nil {
+		return err
+	}
+	// Directories can't be synced on Windows, the rename is flushed by the file system.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	return syncPath(filepath.Dir(path))
+}
+
+// Flush the file or directory.
+func syncPath(path string) error {
+	fh, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = fh.Sync()
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
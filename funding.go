@@ -0,0 +1,90 @@
+package main
+
+import (
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// Donation link of podcast:funding tag.
+type funding struct {
+	URL  string
+	Text string
+}
+
+// License of podcast:license tag: the identifier of the license (like cc-by-4.0) and the URL of its text.
+type license struct {
+	Name string
+	URL  string
+}
+
+func (f funding) String() string {
+	if len(f.Text) > 0 {
+		return f.Text + " " + f.URL
+	}
+	return f.URL
+}
+
+func (l license) String() string {
+	if len(l.URL) > 0 && len(l.Name) > 0 {
+		return l.Name + " " + l.URL
+	}
+	return l.Name + l.URL
+}
+
+// Get podcast:funding links of the extensions.
+func parseFunding(exts ext.Extensions) []funding {
+	result := make([]funding, 0)
+	for _, e := range exts["podcast"]["funding"] {
+		if len(e.Attrs["url"]) > 0 {
+			result = append(result, funding{URL: e.Attrs["url"], Text: e.Value})
+		}
+	}
+	return result
+}
+
+// Get podcast:license of the extensions.
+func parseLicense(exts ext.Extensions) (license, bool) {
+	for _, e := range exts["podcast"]["license"] {
+		l := license{Name: e.Value, URL: e.Attrs["url"]}
+		if len(l.Name) > 0 || len(l.URL) > 0 {
+			return l, true
+		}
+	}
+	return license{}, false
+}
+
+// Get the funding links of the item, the ones of the feed by default.
+func (dl *Glsdl) itemFunding(item *gofeed.Item) []funding {
+	if f := parseFunding(item.Extensions); len(f) > 0 {
+		return f
+	}
+	if dl.feed != nil {
+		return parseFunding(dl.feed.Extensions)
+	}
+	return nil
+}
+
+// Get the license of the item, the license or copyright of the feed by default.
+func (dl *Glsdl) itemLicense(item *gofeed.Item) (license, bool) {
+	if l, ok := parseLicense(item.Extensions); ok {
+		return l, true
+	}
+	if dl.feed == nil {
+		return license{}, false
+	}
+	if l, ok := parseLicense(dl.feed.Extensions); ok {
+		return l, true
+	}
+	return license{Name: dl.feed.Copyright}, len(dl.feed.Copyright) > 0
+}
+
+// Write the funding link to WPAY frame and the license to TCOP and WCOP frames.
+func (dl *Glsdl) setFundingTags(tag *ID3Tag, item *gofeed.Item) {
+	if f := dl.itemFunding(item); len(f) > 0 {
+		tag.SetURL("WPAY", f[0].URL)
+	}
+	if l, ok := dl.itemLicense(item); ok {
+		tag.SetText("TCOP", l.Name)
+		tag.SetURL("WCOP", l.URL)
+	}
+}
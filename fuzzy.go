@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Minimal share of the title words to be found in the filename to consider it a match.
+const (
+	fuzzyThreshold         = 0.6
+	fuzzyThresholdNoNumber = 0.9
+)
+
+// Index of existing files for fuzzy matching against the feed items.
+type fuzzyIndex struct {
+	once  sync.Once
+	mux   sync.Mutex
+	files map[string][]string
+}
+
+// Find the existing file that looks like the episode, e.g. was renamed manually.
+// Episode number must be present in the name and most of the title words should match.
+// Returns the filename relative to the download directory.
+func (dl *Glsdl) matchFile(number, title string) (string, bool) {
+	idx := &dl.fuzzy
+	idx.once.Do(func() {
+		idx.files = make(map[string][]string)
+		entries, err := os.ReadDir(dl.downloadDir)
+		if err != nil {
+			return
+		}
+		// Files named canonically belong to their items already.
+		canonical := make(map[string]bool)
+		if dl.feed != nil {
+			for _, item := range dl.feed.Items {
+				filename, _ := dl.itemFilename(item)
+				canonical[dl.relName(filename)] = true
+			}
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".mp3") ||
+				isLatestFile(name) || canonical[name] || dl.state.HasFilename(name) {
+				continue
+			}
+			idx.files[name] = normalizeWords(name[:len(name)-4])
+		}
+	})
+
+	number = strings.TrimLeft(strings.ToLower(number), "0")
+	titleWords := normalizeWords(title)
+	threshold := fuzzyThreshold
+	if len(number) == 0 {
+		threshold = fuzzyThresholdNoNumber
+	}
+
+	idx.mux.Lock()
+	defer idx.mux.Unlock()
+	best, bestScore := "", 0.0
+	for name, words := range idx.files {
+		score := fuzzyScore(words, number, titleWords)
+		if score >= threshold && score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+	if len(best) == 0 {
+		return "", false
+	}
+
+	// Claim the file so another episode can't match it.
+	delete(idx.files, best)
+	return best, true
+}
+
+// Get the share of the title words found in the name words, zero if the name lacks the episode number.
+// The number is expected without the leading zeros.
+func fuzzyScore(words []string, number string, titleWords []string) float64 {
+	set := make(map[string]bool, len(words))
+	hasNumber := false
+	for _, w := range words {
+		set[w] = true
+		if len(number) > 0 && strings.TrimLeft(w, "0") == number {
+			hasNumber = true
+		}
+	}
+	if len(number) > 0 && !hasNumber {
+		return 0
+	}
+	if len(titleWords) == 0 {
+		return 1
+	}
+	found := 0
+	for _, w := range titleWords {
+		if set[w] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(titleWords))
+}
+
+// Split the string to lower case words consisting of letters and digits only.
+func normalizeWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Report the gaps in the numbering of the archived episodes, like "41, 57–59", and offer to download
+// the missing episodes still available in the feed. Episodes deleted by retention aren't missing.
+// Porcelain format is one line per missing episode with tab-separated number and availability flag.
+func (dl *Glsdl) Gaps(in io.Reader, out io.Writer) error {
+	feed, err := dl.parseFeed()
+	if err != nil {
+		return err
+	}
+	archived := make(map[int]bool)
+	available := make(map[int]*gofeed.Item)
+	for _, item := range feed.Items {
+		number, _, ok := dl.titleParser.ParseTitle(item)
+		n, err := strconv.Atoi(number)
+		if !ok || err != nil || n < 0 {
+			continue
+		}
+		filename, _ := dl.itemFilename(item)
+		e, recorded := dl.state.Get(itemKey(item))
+		if recorded && (e.Deleted || e.duplicated() || dl.fileExists(e.Filename)) || dl.fileExists(dl.relName(filename)) {
+			archived[n] = true
+			continue
+		}
+		if _, ok := dl.itemMedia(item); ok && dl.wanted(item) {
+			available[n] = item
+		}
+	}
+	if len(archived) == 0 && len(available) == 0 {
+		_, _ = fmt.Fprintln(out, msg("gaps.nonumbers"))
+		return nil
+	}
+
+	lo, hi := -1, -1
+	for _, numbers := range []map[int]bool{archived, itemNumbers(available)} {
+		for n := range numbers {
+			if lo < 0 || n < lo {
+				lo = n
+			}
+			hi = max(hi, n)
+		}
+	}
+	missing, unavailable := make([]int, 0), make([]int, 0)
+	for n := lo; n <= hi; n++ {
+		switch {
+		case archived[n]:
+		case available[n] != nil:
+			missing = append(missing, n)
+		default:
+			unavailable = append(unavailable, n)
+		}
+	}
+	if dl.porcelain {
+		all := append(append([]int(nil), missing...), unavailable...)
+		sort.Ints(all)
+		for _, n := range all {
+			_, _ = fmt.Fprintf(out, "%d\t%t\n", n, available[n] != nil)
+		}
+		return nil
+	}
+	if len(missing) == 0 && len(unavailable) == 0 {
+		_, _ = fmt.Fprintln(out, msg("gaps.none", lo, hi))
+		return nil
+	}
+	if len(missing) > 0 {
+		_, _ = fmt.Fprintln(out, msg("gaps.missing", formatRanges(missing)))
+	}
+	if len(unavailable) > 0 {
+		_, _ = fmt.Fprintln(out, msg("gaps.unavailable", formatRanges(unavailable)))
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	_, _ = fmt.Fprint(out, msg("gaps.fetch", len(missing)))
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+	if answer := strings.ToLower(strings.TrimSpace(scanner.Text())); answer != "y" && answer != "yes" {
+		return nil
+	}
+	selected := make(map[*gofeed.Item]bool, len(missing))
+	for _, n := range missing {
+		selected[available[n]] = true
+	}
+	if dl.pool == nil {
+		dl.pool = newPool(dl.threads, *tagThreads, 0)
+	}
+	if dl.progress == nil {
+		dl.progress = newProgress()
+	}
+	dl.processItems(feed.Items, func(item *gofeed.Item) bool {
+		return selected[item]
+	})
+	dl.waitGroup.Wait()
+	dl.retryFailed()
+	_, _ = fmt.Fprintln(out, strings.Join(dl.Report(), "\n"))
+	return dl.state.Save()
+}
+
+// Get the set of the numbers of the items.
+func itemNumbers(items map[int]*gofeed.Item) map[int]bool {
+	result := make(map[int]bool, len(items))
+	for n := range items {
+		result[n] = true
+	}
+	return result
+}
+
+// Format the sorted numbers collapsing the sequences to ranges, like "41, 57–59".
+func formatRanges(numbers []int) string {
+	parts := make([]string, 0)
+	for i := 0; i < len(numbers); {
+		j := i
+		for j+1 < len(numbers) && numbers[j+1] == numbers[j]+1 {
+			j++
+		}
+		part := strconv.Itoa(numbers[i])
+		if j > i {
+			part += "–" + strconv.Itoa(numbers[j])
+		}
+		parts = append(parts, part)
+		i = j + 1
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Download one episode of the feed by its number, GUID or "latest" for the newest one.
+// The episode is named and tagged like by fetch, but the filters of the feed don't apply to it
+// and the episode deleted by retention is downloaded again.
+func (dl *Glsdl) Get(query string, out io.Writer) error {
+	if len(query) == 0 {
+		return fmt.Errorf("%s", msg("get.noquery"))
+	}
+	feed, err := dl.parseFeed()
+	if err != nil {
+		return err
+	}
+	var item *gofeed.Item
+	if query == "latest" {
+		if items := chronologicalItems(feed); len(items) > 0 {
+			item = items[len(items)-1]
+		}
+	} else {
+		for _, it := range feed.Items {
+			if dl.itemMatches(it, query) {
+				item = it
+				break
+			}
+		}
+	}
+	if item == nil {
+		return fmt.Errorf("episode %s of %s not found", query, dl.conf.Name)
+	}
+	if _, ok := dl.itemMedia(item); !ok {
+		return fmt.Errorf("episode %s of %s has no media", query, dl.conf.Name)
+	}
+
+	key := itemKey(item)
+	if e, ok := dl.state.Get(key); ok && e.Deleted {
+		e.Deleted = false
+		dl.state.Put(key, e)
+	}
+	dl.requested = item
+	if dl.pool == nil {
+		dl.pool = newPool(dl.threads, *tagThreads, 0)
+	}
+	if dl.progress == nil {
+		dl.progress = newProgress()
+	}
+	dl.processItems([]*gofeed.Item{item}, func(*gofeed.Item) bool {
+		return true
+	})
+	dl.waitGroup.Wait()
+	dl.retryFailed()
+	_, _ = fmt.Fprintln(out, strings.Join(dl.Report(), "\n"))
+	return dl.state.Save()
+}
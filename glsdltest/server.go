@@ -0,0 +1,204 @@
+// Package glsdltest provides the in-process fake feed and media server for exercising glsdl
+// download flows without the network.
+package glsdltest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Episode served by the fake server.
+type Episode struct {
+	GUID      string
+	Title     string
+	Published time.Time
+	// Media file contents, see MP3. Type is audio/mpeg if empty.
+	Media []byte
+	Type  string
+}
+
+// Fake podcast server: serves RSS feed at /feed.xml and the media files of its episodes at /media/<guid>.
+// Requests can be made to fail to exercise the retries.
+type Server struct {
+	*httptest.Server
+	title    string
+	mux      sync.Mutex
+	episodes []Episode
+	requests map[string]int
+	failures map[string]failure
+}
+
+// Failure injected for the path.
+type failure struct {
+	status int
+	times  int
+}
+
+// Start the server of the feed with the episodes, Close should be called after use.
+func NewServer(title string, episodes ...Episode) *Server {
+	s := &Server{
+		title:    title,
+		episodes: episodes,
+		requests: make(map[string]int),
+		failures: make(map[string]failure),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Get URL of the feed.
+func (s *Server) FeedURL() string {
+	return s.URL + "/feed.xml"
+}
+
+// Get URL of the media file of the episode.
+func (s *Server) MediaURL(guid string) string {
+	return s.URL + mediaPath(guid)
+}
+
+// Publish the episode, it's shown first in the feed.
+func (s *Server) Add(e Episode) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.episodes = append([]Episode{e}, s.episodes...)
+}
+
+// Make the next requests of the path fail with the status.
+func (s *Server) Fail(path string, status, times int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.failures[path] = failure{status: status, times: times}
+}
+
+// Get the number of requests of the path made so far.
+func (s *Server) Requests(path string) int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.requests[path]
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	s.requests[r.URL.Path]++
+	f, failing := s.failures[r.URL.Path]
+	if failing {
+		if f.times--; f.times <= 0 {
+			delete(s.failures, r.URL.Path)
+		} else {
+			s.failures[r.URL.Path] = f
+		}
+	}
+	episodes := append([]Episode(nil), s.episodes...)
+	s.mux.Unlock()
+	if failing {
+		http.Error(w, http.StatusText(f.status), f.status)
+		return
+	}
+
+	if r.URL.Path == "/feed.xml" {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		_, _ = w.Write(s.feed(episodes))
+		return
+	}
+	for _, e := range episodes {
+		if r.URL.Path != mediaPath(e.GUID) {
+			continue
+		}
+		w.Header().Set("Content-Type", mediaType(e))
+		w.Header().Set("Content-Length", strconv.Itoa(len(e.Media)))
+		if !e.Published.IsZero() {
+			w.Header().Set("Last-Modified", e.Published.UTC().Format(http.TimeFormat))
+		}
+		if r.Method != http.MethodHead {
+			_, _ = w.Write(e.Media)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// RSS document of the feed.
+type rss struct {
+	XMLName xml.Name  `xml:"rss"`
+	Version string    `xml:"version,attr"`
+	Title   string    `xml:"channel>title"`
+	Items   []rssItem `xml:"channel>item"`
+}
+
+type rssItem struct {
+	Title     string       `xml:"title"`
+	GUID      string       `xml:"guid"`
+	PubDate   string       `xml:"pubDate,omitempty"`
+	Enclosure rssEnclosure `xml:"enclosure"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int    `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// Encode the feed of the episodes.
+func (s *Server) feed(episodes []Episode) []byte {
+	doc := rss{Version: "2.0", Title: s.title}
+	for _, e := range episodes {
+		item := rssItem{
+			Title:     e.Title,
+			GUID:      e.GUID,
+			Enclosure: rssEnclosure{URL: s.MediaURL(e.GUID), Length: len(e.Media), Type: mediaType(e)},
+		}
+		if !e.Published.IsZero() {
+			item.PubDate = e.Published.Format(time.RFC1123Z)
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return append([]byte(xml.Header), data...)
+}
+
+func mediaPath(guid string) string {
+	return "/media/" + strings.NewReplacer("/", "_", "?", "_", "#", "_").Replace(guid) + ".mp3"
+}
+
+func mediaType(e Episode) string {
+	if len(e.Type) > 0 {
+		return e.Type
+	}
+	return "audio/mpeg"
+}
+
+// Make fake MP3 contents of the size: MPEG-1 Layer III frame headers followed by deterministic data,
+// enough for the tagging and sniffing, not for playing.
+func MP3(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 31)
+	}
+	for i := 0; i+4 <= size; i += 417 {
+		copy(data[i:], []byte{0xFF, 0xFB, 0x90, 0x64})
+	}
+	return data
+}
+
+// Make the episodes numbered from 1, published daily from the time.
+func Episodes(n int, from time.Time) []Episode {
+	result := make([]Episode, 0, n)
+	for i := n; i >= 1; i-- {
+		result = append(result, Episode{
+			GUID:      fmt.Sprintf("episode-%03d", i),
+			Title:     fmt.Sprintf("Episode %03d. Title %d", i, i),
+			Published: from.Add(time.Duration(i-1) * 24 * time.Hour),
+			Media:     MP3(4096 + i),
+		})
+	}
+	return result
+}
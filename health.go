@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Health report of the daemon served by /healthz.
+type healthReport struct {
+	// ok, stale or failing.
+	Status  string       `json:"status"`
+	Running bool         `json:"running"`
+	Next    time.Time    `json:"next_run"`
+	Feeds   []healthFeed `json:"feeds"`
+}
+
+// Health of one feed.
+type healthFeed struct {
+	Name    string     `json:"name"`
+	Run     *time.Time `json:"last_run,omitempty"`
+	Success *time.Time `json:"last_success,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	// Feed wasn't fetched successfully for two intervals.
+	Stale bool `json:"stale"`
+	// Feed failed several runs in a row or has no new episodes for long.
+	Warning string `json:"warning,omitempty"`
+}
+
+// Start serving /healthz on the address.
+func (d *daemon) serveHealth(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", d.healthz)
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Println(err)
+		}
+	}()
+	return nil
+}
+
+// Report the health, responds with 503 status if any feed is stale or the last run failed.
+func (d *daemon) healthz(w http.ResponseWriter, _ *http.Request) {
+	report := d.health(time.Now())
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// Build the health report.
+// Feeds not fetched yet aren't stale until two intervals since the daemon start.
+func (d *daemon) health(now time.Time) healthReport {
+	d.mux.Lock()
+	report := healthReport{Status: "ok", Running: d.running, Next: d.next, Feeds: make([]healthFeed, 0)}
+	started := d.started
+	d.mux.Unlock()
+
+	deadline := now.Add(-2 * d.interval)
+	names, statuses := d.statuses("")
+	for i, s := range statuses {
+		f, success := healthFeed{Name: names[i]}, time.Time{}
+		if s != nil {
+			f.Run, f.Error, f.Warning, success = &s.Run, s.Err, s.Warning, s.Success
+			if !success.IsZero() {
+				f.Success = &success
+			}
+		}
+		f.Stale = success.Before(deadline) && started.Before(deadline)
+		switch {
+		case f.Stale:
+			report.Status = "stale"
+		case len(f.Error) > 0 && report.Status == "ok":
+			report.Status = "failing"
+		}
+		report.Feeds = append(report.Feeds, f)
+	}
+	return report
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	htmltemplate "html/template"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Name of the page listing the archived episodes, see html profile.
+const IndexFile = "index.html"
+
+// Maximum length of the show notes excerpt in runes.
+const notesExcerpt = 300
+
+// Profile writing index.html to the download directory: the archived episodes, newest first, with
+// the players, dates, links and show notes excerpts, so the archive is browsable from any device.
+type htmlProfile struct{}
+
+func (p htmlProfile) Export(dl *Glsdl) error {
+	page := indexPage{Lang: lang, Title: dl.conf.Name, Updated: time.Now().Format("2006-01-02 15:04")}
+	if dl.feed != nil {
+		if len(dl.feed.Title) > 0 {
+			page.Title = dl.feed.Title
+		}
+		page.Link, page.Description = dl.feed.Link, excerpt(dl.feed.Description)
+	}
+	if dl.fileExists(CoverFile) {
+		page.Cover = CoverFile
+	}
+	archived := dl.archivedItems()
+	for i := len(archived) - 1; i >= 0; i-- {
+		a := archived[i]
+		e, _ := dl.state.Get(itemKey(a.Item))
+		prefix, title := dl.parseTitle(a.Item)
+		ep := indexEpisode{
+			Number: prefix,
+			Title:  title,
+			File:   (&url.URL{Path: a.Filename}).String(),
+			Link:   a.Item.Link,
+			GUID:   a.Item.GUID,
+			Notes:  excerpt(itemNotes(a.Item.Description, a.Item.Content)),
+			Played: e.Played != nil,
+			Video:  isVideoFile(a.Filename),
+		}
+		if published := itemPublished(a.Item); !published.IsZero() {
+			ep.Date = published.Format("2006-01-02")
+		}
+		if d, ok := itemDuration(a.Item); ok {
+			ep.Duration = d.String()
+		}
+		page.Episodes = append(page.Episodes, ep)
+	}
+	var buf bytes.Buffer
+	if err := indexTemplate.Execute(&buf, page); err != nil {
+		return err
+	}
+	tmp := dl.downloadDir + ps + IndexFile + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dl.downloadDir+ps+IndexFile)
+}
+
+// Data of index.html.
+type indexPage struct {
+	Lang        string
+	Title       string
+	Link        string
+	Description string
+	Cover       string
+	Updated     string
+	Episodes    []indexEpisode
+}
+
+// Archived episode of index.html, the file is the escaped path relative to the page.
+type indexEpisode struct {
+	Number   string
+	Title    string
+	Date     string
+	Duration string
+	File     string
+	Link     string
+	GUID     string
+	Notes    string
+	Played   bool
+	Video    bool
+}
+
+// Get the show notes of the item, the description is usually shorter than the content.
+func itemNotes(description, content string) string {
+	if len(strings.TrimSpace(description)) > 0 {
+		return description
+	}
+	return content
+}
+
+var (
+	htmlTags   = regexp.MustCompile(`<[^>]*>`)
+	whitespace = regexp.MustCompile(`\s+`)
+)
+
+// Get the plain text beginning of the HTML notes.
+func excerpt(notes string) string {
+	text := html.UnescapeString(htmlTags.ReplaceAllString(notes, " "))
+	text = strings.TrimSpace(whitespace.ReplaceAllString(text, " "))
+	if runes := []rune(text); len(runes) > notesExcerpt {
+		text = strings.TrimSpace(string(runes[:notesExcerpt])) + "…"
+	}
+	return text
+}
+
+var indexTemplate = htmltemplate.Must(htmltemplate.New(IndexFile).Funcs(htmltemplate.FuncMap{"msg": msg}).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 0 auto; padding: 1em; color: #222; }
+header img { float: right; width: 8em; margin-left: 1em; }
+article { clear: both; border-top: 1px solid #ddd; padding: 0.5em 0; }
+audio, video { width: 100%; }
+.meta { color: #777; font-size: 0.9em; }
+.guid { color: #aaa; font-size: 0.8em; word-break: break-all; }
+</style>
+</head>
+<body>
+<header>
+{{if .Cover}}<img src="{{.Cover}}" alt="">{{end}}
+<h1>{{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h1>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+<p class="meta">{{msg "index.summary" (len .Episodes) .Updated}}</p>
+</header>
+{{range .Episodes}}<article>
+<h2>{{if .Number}}{{.Number}}. {{end}}<a href="{{.File}}">{{.Title}}</a></h2>
+<p class="meta">{{.Date}}{{if .Duration}} · {{.Duration}}{{end}}{{if .Played}} · {{msg "list.played"}}{{end}}{{if .Link}} · <a href="{{.Link}}">{{msg "index.link"}}</a>{{end}}</p>
+{{if .Video}}<video controls preload="none" src="{{.File}}"></video>{{else}}<audio controls preload="none" src="{{.File}}"></audio>{{end}}
+{{if .Notes}}<p>{{.Notes}}</p>{{end}}
+{{if .GUID}}<p class="guid">{{.GUID}}</p>{{end}}
+</article>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,18 @@
+//go:build http3
+
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// Create HTTP/3 transport.
+func newHTTP3Transport(conf *tls.Config) (http.RoundTripper, error) {
+	if conf != nil {
+		conf = conf.Clone()
+	}
+	return &http3.Transport{TLSClientConfig: conf}, nil
+}
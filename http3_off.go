@@ -0,0 +1,14 @@
+//go:build !http3
+
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+)
+
+// HTTP/3 requires QUIC implementation, which isn't included in the default build.
+func newHTTP3Transport(_ *tls.Config) (http.RoundTripper, error) {
+	return nil, errors.New("HTTP/3 isn't supported by this build, build it with -tags http3")
+}
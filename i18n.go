@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Default language of the messages.
+const DefaultLang = "en"
+
+// Current language of the messages.
+var lang = DefaultLang
+
+// Message catalogs by language. Values are fmt format strings.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"feed":                "Feed %s:",
+		"progress":            "Progress:",
+		"cover":               "cover file",
+		"statistics":          "Statistics:",
+		"stat.downloaded":     "%d files were downloaded",
+		"stat.processed":      "%d files were processes",
+		"stat.failed":         "%d files were failed",
+		"stat.spent":          "%s spent",
+		"skipped":             "skipped",
+		"skip.no-media":       "no media file",
+		"skip.title":          "filtered by title",
+		"skip.episode-type":   "filtered by episode type",
+		"skip.keyword":        "filtered by keyword",
+		"skip.duration":       "filtered by duration",
+		"skip.deleted":        "deleted by retention",
+		"skip.dup":            "kept by another feed",
+		"skip.quota":          "monthly quota exceeded",
+		"failed":              "failed: %s",
+		"orphans.none":        "No orphan files found.",
+		"orphans.matches":     "matches %s",
+		"orphans.adopt":       "[a]dopt, ",
+		"orphans.choices":     "[r]ename, [d]elete, [s]kip: ",
+		"orphans.newname":     "new name: ",
+		"orphans.noadopt":     "nothing to adopt, skipped",
+		"migrate.skipped":     "%s skipped, %s already exists",
+		"migrate.renamed":     "%d files were renamed",
+		"existing.nomatch":    "%s doesn't match any episode",
+		"gaps.nonumbers":      "No numbered episodes.",
+		"gaps.none":           "No gaps, episodes %d-%d are archived.",
+		"gaps.missing":        "Missing episodes: %s",
+		"gaps.unavailable":    "Not in the feed anymore: %s",
+		"gaps.fetch":          "Download %d missing episodes? [y/N] ",
+		"service.installed":   "Service %s is installed and started",
+		"service.uninstalled": "Service %s is stopped and removed",
+		"service.usage":       "Usage: glsdl service install|uninstall|run",
+		"existing.imported":   "%d files were imported",
+		"unknown_command":     "unknown command %q",
+		"unknown_id3":         "unknown ID3 version %q",
+		"get.noquery":         "episode number, GUID or latest is required",
+		"subs.nourl":          "feed URL is required",
+		"subs.noname":         "feed name is required",
+		"subs.subscribed":     "%s is subscribed already as %s",
+		"subs.exists":         "feed %s exists already",
+		"subs.direxists":      "directory %s exists already",
+		"subs.added":          "feed %s added",
+		"subs.removed":        "feed %s removed, the episodes are kept in %s",
+		"subs.renamed":        "feed %s renamed to %s",
+		"subs.enabled":        "feed %s enabled",
+		"subs.disabled":       "feed %s disabled",
+		"order.unknown":       "unknown download order %q, use newest, oldest, smallest or largest",
+		"cast.playing":        "Casting %s to %s, press Ctrl+C to stop.",
+		"retention.deleted":   "%s deleted, played long ago",
+		"sync.copied":         "%s copied",
+		"sync.removed":        "%s removed, played",
+		"sync.quota":          "%s skipped, quota exceeded",
+		"sync.notarget":       "sync target isn't set, use -target flag",
+		"locked":              "%s is being processed by another run, skipped",
+		"check.download":      "download %s",
+		"check.redownload":    "download again %s, republished",
+		"check.retag":         "retag %s",
+		"check.corrupt":       "quarantine and download again %s, corrupt",
+		"check.prune":         "delete %s, played",
+		"check.none":          "Nothing to do.",
+		"readonly.refused":    "%s command changes the archive, it isn't allowed with -read-only flag",
+		"export.noarchive":    "archive path is required",
+		"pprof.noaddr":        "-pprof requires -debug-http address",
+		"export.feed":         "%s: %d episodes exported",
+		"import.nomanifest":   "manifest.json not found in the archive",
+		"import.feed":         "%s: %d episodes imported to %s",
+		"import.unknown":      "the feed isn't configured, add %s to the config",
+		"notify.episode":      "New episode of %s",
+		"notify.digest":       "%d new episodes across %d shows",
+		"notify.warning":      "Feed %s needs attention",
+		"health.failing":      "failed %d runs in a row: %s",
+		"health.dormant":      "no new episodes for %d days, usually every %d days; the feed may be dead or moved",
+		"notify.duration":     "Duration",
+		"list.played":         "played",
+		"index.summary":       "%d episodes, updated %s",
+		"index.link":          "episode page",
+		"list.republished":    "republished",
+		"list.verified":       "verified",
+		"list.funding":        "Support: %s",
+		"list.license":        "License: %s",
+		"list.page":           "Page %d of %d, %d episodes. Use -page flag to see others.",
+		"daemon.none":         "daemon isn't running",
+		"daemon.exists":       "daemon is already running at %s",
+		"daemon.listening":    "daemon is listening on %s",
+		"daemon.reloaded":     "config %s reloaded",
+		"daemon.paused":       "downloads are paused, run resume command to continue",
+		"daemon.resumed":      "downloads are resumed",
+		"daemon.pausedstatus": "Downloads are paused, %d episodes pending",
+		"completion.unknown":  "unknown shell %q, use bash, zsh or fish",
+		"init.overwrite":      "%s already exists, overwrite it? [y/N] ",
+		"init.url":            "Feed URL (empty to finish): ",
+		"init.found":          "found %q with %d episodes",
+		"init.name":           "Feed name: ",
+		"init.dir":            "Download directory: ",
+		"init.threads":        "Threads: ",
+		"init.retention":      "Delete played episodes after days (0 to keep them): ",
+		"init.number":         "non-negative number expected",
+		"init.written":        "config %s written",
+		"ip.both":             "-4 and -6 flags are mutually exclusive",
+		"quota.exceeded":      "monthly quota %s is exceeded, downloads are paused until the next month",
+		"quarantined":         "%s is corrupt (%v), moved to the quarantine to download again",
+		"auth.none":           "feed %s has no auth settings",
+		"auth.prompt":         "Open %s and enter the code %s",
+		"auth.done":           "feed %s authorized",
+		"plan":                "%d episodes to download, %s",
+		"progress.line":       "%d/%d episodes, %s/%s, %s/s, ETA %s",
+		"summary.header":      "#\tEpisode\tAction\tSize\tTime\tSpeed\tError",
+		"daemon.running":      "Daemon: processing %s",
+		"daemon.idle":         "Daemon: idle, next run at %s",
+		"daemon.nofeed":       "%s: not processed yet",
+		"feed.nomatch":        "no feed matched",
+		"daemon.feed":         "%s: last run at %s, %d downloaded, %d failed",
+		"daemon.feederr":      "%s: last run at %s failed: %s",
+	},
+	"ru": {
+		"feed":                "Подкаст %s:",
+		"progress":            "Прогресс:",
+		"cover":               "обложка",
+		"statistics":          "Статистика:",
+		"stat.downloaded":     "загружено файлов: %d",
+		"stat.processed":      "обработано файлов: %d",
+		"stat.failed":         "ошибок: %d",
+		"stat.spent":          "затрачено: %s",
+		"skipped":             "пропущен",
+		"skip.no-media":       "нет медиафайла",
+		"skip.title":          "отфильтрован по названию",
+		"skip.episode-type":   "отфильтрован по типу выпуска",
+		"skip.keyword":        "отфильтрован по ключевому слову",
+		"skip.duration":       "отфильтрован по длительности",
+		"skip.deleted":        "удалён по сроку хранения",
+		"skip.dup":            "хранится другим подкастом",
+		"skip.quota":          "превышена месячная квота",
+		"failed":              "ошибка: %s",
+		"orphans.none":        "Посторонних файлов не найдено.",
+		"orphans.matches":     "соответствует %s",
+		"orphans.adopt":       "[a] принять, ",
+		"orphans.choices":     "[r] переименовать, [d] удалить, [s] пропустить: ",
+		"orphans.newname":     "новое имя: ",
+		"orphans.noadopt":     "принимать нечего, пропущен",
+		"migrate.skipped":     "%s пропущен, %s уже существует",
+		"migrate.renamed":     "переименовано файлов: %d",
+		"existing.nomatch":    "%s не соответствует ни одному выпуску",
+		"gaps.nonumbers":      "Нет нумерованных выпусков.",
+		"gaps.none":           "Пропусков нет, выпуски %d-%d в архиве.",
+		"gaps.missing":        "Пропущенные выпуски: %s",
+		"gaps.unavailable":    "Уже нет в фиде: %s",
+		"gaps.fetch":          "Загрузить пропущенные выпуски (%d)? [y/N] ",
+		"service.installed":   "Служба %s установлена и запущена",
+		"service.uninstalled": "Служба %s остановлена и удалена",
+		"service.usage":       "Использование: glsdl service install|uninstall|run",
+		"existing.imported":   "импортировано файлов: %d",
+		"unknown_command":     "неизвестная команда %q",
+		"unknown_id3":         "неизвестная версия ID3 %q",
+		"get.noquery":         "требуется номер выпуска, GUID или latest",
+		"subs.nourl":          "требуется адрес подкаста",
+		"subs.noname":         "требуется название подкаста",
+		"subs.subscribed":     "подписка на %s уже есть: %s",
+		"subs.exists":         "подкаст %s уже есть",
+		"subs.direxists":      "каталог %s уже существует",
+		"subs.added":          "подкаст %s добавлен",
+		"subs.removed":        "подкаст %s удалён, выпуски остались в %s",
+		"subs.renamed":        "подкаст %s переименован в %s",
+		"subs.enabled":        "подкаст %s включён",
+		"subs.disabled":       "подкаст %s отключён",
+		"order.unknown":       "неизвестный порядок загрузки %q, используйте newest, oldest, smallest или largest",
+		"cast.playing":        "Трансляция %s на %s, нажмите Ctrl+C для остановки.",
+		"retention.deleted":   "%s удалён, прослушан давно",
+		"sync.copied":         "%s скопирован",
+		"sync.removed":        "%s удалён, прослушан",
+		"sync.quota":          "%s пропущен, превышена квота",
+		"sync.notarget":       "не задано устройство, используйте флаг -target",
+		"locked":              "%s обрабатывается другим запуском, пропущен",
+		"check.download":      "загрузить %s",
+		"check.redownload":    "загрузить заново %s, переопубликован",
+		"check.retag":         "обновить теги %s",
+		"check.corrupt":       "поместить в карантин и загрузить заново %s, повреждён",
+		"check.prune":         "удалить %s, прослушан",
+		"check.none":          "Нечего делать.",
+		"readonly.refused":    "команда %s изменяет архив, она недоступна с флагом -read-only",
+		"export.noarchive":    "не указан путь к архиву",
+		"pprof.noaddr":        "для -pprof нужен адрес -debug-http",
+		"export.feed":         "%s: экспортировано выпусков: %d",
+		"import.nomanifest":   "в архиве нет manifest.json",
+		"import.feed":         "%s: импортировано выпусков: %d в %s",
+		"import.unknown":      "подписка не настроена, добавьте %s в конфиг",
+		"notify.episode":      "Новый выпуск %s",
+		"notify.digest":       "новых выпусков: %d, подкастов: %d",
+		"notify.warning":      "Подкаст %s требует внимания",
+		"health.failing":      "ошибки %d запусков подряд: %s",
+		"health.dormant":      "нет новых выпусков %d дн., обычно каждые %d дн.; возможно, подкаст закрыт или переехал",
+		"notify.duration":     "Длительность",
+		"list.played":         "прослушан",
+		"index.summary":       "выпусков: %d, обновлено %s",
+		"index.link":          "страница выпуска",
+		"list.republished":    "переопубликован",
+		"list.verified":       "проверен",
+		"list.funding":        "Поддержать: %s",
+		"list.license":        "Лицензия: %s",
+		"list.page":           "Страница %d из %d, выпусков: %d. Другие страницы выводятся с флагом -page.",
+		"daemon.none":         "демон не запущен",
+		"daemon.exists":       "демон уже запущен на %s",
+		"daemon.listening":    "демон слушает %s",
+		"daemon.reloaded":     "конфигурация %s перечитана",
+		"daemon.paused":       "загрузки приостановлены, выполните команду resume для продолжения",
+		"daemon.resumed":      "загрузки возобновлены",
+		"daemon.pausedstatus": "Загрузки приостановлены, ожидают выпусков: %d",
+		"completion.unknown":  "неизвестная оболочка %q, используйте bash, zsh или fish",
+		"init.overwrite":      "%s уже существует, перезаписать? [y/N] ",
+		"init.url":            "Адрес подкаста (пусто для завершения): ",
+		"init.found":          "найден %q, выпусков: %d",
+		"init.name":           "Название: ",
+		"init.dir":            "Каталог загрузки: ",
+		"init.threads":        "Потоков: ",
+		"init.retention":      "Удалять прослушанные выпуски через дней (0 чтобы не удалять): ",
+		"init.number":         "ожидается неотрицательное число",
+		"init.written":        "конфигурация %s записана",
+		"ip.both":             "флаги -4 и -6 несовместимы",
+		"quota.exceeded":      "месячная квота %s исчерпана, загрузки приостановлены до следующего месяца",
+		"quarantined":         "%s повреждён (%v), перемещён в карантин для повторной загрузки",
+		"auth.none":           "у фида %s нет настроек авторизации",
+		"auth.prompt":         "Откройте %s и введите код %s",
+		"auth.done":           "фид %s авторизован",
+		"plan":                "выпусков к загрузке: %d, %s",
+		"progress.line":       "выпусков %d/%d, %s/%s, %s/с, осталось %s",
+		"summary.header":      "#\tВыпуск\tДействие\tРазмер\tВремя\tСкорость\tОшибка",
+		"daemon.running":      "Демон: обработка %s",
+		"daemon.idle":         "Демон: ожидание, следующий запуск в %s",
+		"daemon.nofeed":       "%s: ещё не обработан",
+		"feed.nomatch":        "нет подходящих лент",
+		"daemon.feed":         "%s: последний запуск в %s, загружено %d, ошибок %d",
+		"daemon.feederr":      "%s: последний запуск в %s завершился ошибкой: %s",
+	},
+}
+
+// Get the localized message by its key.
+// Falls back to the default language and then to the key itself.
+func msg(key string, args ...interface{}) string {
+	format, ok := catalogs[lang][key]
+	if !ok {
+		if format, ok = catalogs[DefaultLang][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Detect the language from the flag value or the locale env vars.
+// Locales like "ru_RU.UTF-8" are reduced to the language code.
+func detectLang(flagValue string) string {
+	candidates := []string{flagValue, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, c := range candidates {
+		if len(c) == 0 {
+			continue
+		}
+		c = strings.ToLower(c)
+		if i := strings.IndexAny(c, "_.@-"); i >= 0 {
+			c = c[:i]
+		}
+		if _, ok := catalogs[c]; ok {
+			return c
+		}
+	}
+	return DefaultLang
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Supported ID3v2 versions.
+const (
+	ID3v23 byte = 3
+	ID3v24 byte = 4
+
+	id3HeaderSize = 10
+	id3v1Size     = 128
+)
+
+// Text encodings of the ID3v2 frames.
+const (
+	encISO88591 byte = 0
+	encUTF16    byte = 1
+	encUTF16BE  byte = 2
+	encUTF8     byte = 3
+)
+
+// Frames which exist only in one of the versions, they are dropped on version change.
+var (
+	id3v23Only = map[string]bool{"TYER": true, "TDAT": true, "TIME": true, "TRDA": true, "TORY": true,
+		"TSIZ": true, "IPLS": true, "EQUA": true, "RVAD": true}
+	id3v24Only = map[string]bool{"TDRC": true, "TDRL": true, "TDTG": true, "TDEN": true, "TDOR": true,
+		"TSOA": true, "TSOP": true, "TSOT": true, "TMOO": true, "TPRO": true, "TSST": true, "TIPL": true,
+		"TMCL": true, "SEEK": true, "ASPI": true, "EQU2": true, "RVA2": true}
+)
+
+// ID3v1 genre indexes, unknown genres are written as 255.
+var id3v1Genres = map[string]byte{
+	"other":   12,
+	"speech":  101,
+	"podcast": 186,
+}
+
+// Raw ID3v2 frame.
+type id3Frame struct {
+	ID    string
+	Flags [2]byte
+	Body  []byte
+}
+
+// ID3v2 tag of the media file.
+type ID3Tag struct {
+	version byte
+	// Version of the tag in the file, 0 if the file has no tag.
+	origVersion byte
+	// Size of the tag in the file including header and padding.
+	origSize int64
+	frames   []id3Frame
+	// Padding added when the whole file is rewritten, so the next updates fit in place.
+	padding int
+}
+
+// Read the ID3v2 tag of the file.
+// Files without tag give an empty ID3v2.3 tag. Tags which frames can't be parsed (ID3v2.2, unsynchronised)
+// are replaced entirely on write.
+func ReadID3(path string) (*ID3Tag, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	t := &ID3Tag{version: ID3v23}
+	var header [id3HeaderSize]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return t, nil
+		}
+		return nil, err
+	}
+	if string(header[:3]) != "ID3" {
+		return t, nil
+	}
+	size := synchsafe(header[6:10])
+	t.origVersion = header[3]
+	t.origSize = int64(id3HeaderSize + size)
+	if header[5]&0x10 != 0 {
+		// Footer of the ID3v2.4 tag.
+		t.origSize += id3HeaderSize
+	}
+	if (t.origVersion != ID3v23 && t.origVersion != ID3v24) || header[5]&0x80 != 0 {
+		return t, nil
+	}
+	t.version = t.origVersion
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(f, data); err != nil {
+		return nil, fmt.Errorf("%s: broken ID3v2 tag: %w", path, err)
+	}
+	if header[5]&0x40 != 0 && len(data) >= 4 {
+		// Skip the extended header.
+		n := int(binary.BigEndian.Uint32(data)) + 4
+		if t.origVersion == ID3v24 {
+			n = synchsafe(data[:4])
+		}
+		if n > len(data) {
+			return t, nil
+		}
+		data = data[n:]
+	}
+	for len(data) >= id3HeaderSize && data[0] != 0 {
+		n := int(binary.BigEndian.Uint32(data[4:8]))
+		if t.origVersion == ID3v24 {
+			n = synchsafe(data[4:8])
+		}
+		if n < 0 || id3HeaderSize+n > len(data) {
+			break
+		}
+		t.frames = append(t.frames, id3Frame{
+			ID:    string(data[:4]),
+			Flags: [2]byte{data[8], data[9]},
+			Body:  data[id3HeaderSize : id3HeaderSize+n],
+		})
+		data = data[id3HeaderSize+n:]
+	}
+
+	return t, nil
+}
+
+// Get the version the tag will be written with.
+func (t *ID3Tag) Version() byte {
+	return t.version
+}
+
+// Set the version the tag will be written with.
+// Frames that can't be converted to the new version are dropped.
+func (t *ID3Tag) SetVersion(version byte) {
+	if version == t.version {
+		return
+	}
+	only := id3v23Only
+	if version == ID3v23 {
+		only = id3v24Only
+	}
+	frames := t.frames[:0]
+	for _, fr := range t.frames {
+		// Format flags (compression, encryption, etc.) have different layout in the versions.
+		if only[fr.ID] || fr.Flags[1] != 0 {
+			continue
+		}
+		fr.Flags[0] = 0
+		if len(fr.Body) > 0 && fr.Body[0] == encUTF8 && version == ID3v23 {
+			// UTF-8 isn't allowed in ID3v2.3, re-encode the text frames and drop the others.
+			if fr.ID[0] != 'T' || fr.ID == "TXXX" {
+				continue
+			}
+			fr.Body = encodeText(version, decodeText(fr.Body))
+		}
+		frames = append(frames, fr)
+	}
+	t.frames = frames
+	t.version = version
+}
+
+// Get the text of the frame.
+func (t *ID3Tag) Text(id string) string {
+	for _, fr := range t.frames {
+		if fr.ID == id {
+			return decodeText(fr.Body)
+		}
+	}
+	return ""
+}
+
+// Replace the frames with the text frame. Empty text just deletes the frames.
+func (t *ID3Tag) SetText(id, text string) {
+	t.DeleteFrames(id)
+	if len(text) > 0 {
+		t.frames = append(t.frames, id3Frame{ID: id, Body: encodeText(t.version, text)})
+	}
+}
+
+// Delete all frames with the ID.
+func (t *ID3Tag) DeleteFrames(id string) {
+	frames := t.frames[:0]
+	for _, fr := range t.frames {
+		if fr.ID != id {
+			frames = append(frames, fr)
+		}
+	}
+	t.frames = frames
+}
+
+// Delete all frames.
+func (t *ID3Tag) DeleteAll() {
+	t.frames = t.frames[:0]
+}
+
+// Replace the frames with the URL link frame. Empty URL just deletes the frames.
+func (t *ID3Tag) SetURL(id, url string) {
+	t.DeleteFrames(id)
+	if len(url) > 0 {
+		body := make([]byte, len(url))
+		putLatin1(body, url)
+		t.frames = append(t.frames, id3Frame{ID: id, Body: body})
+	}
+}
+
+func (t *ID3Tag) SetTitle(title string)         { t.SetText("TIT2", title) }
+func (t *ID3Tag) SetArtist(artist string)       { t.SetText("TPE1", artist) }
+func (t *ID3Tag) SetAlbum(album string)         { t.SetText("TALB", album) }
+func (t *ID3Tag) SetPublisher(publisher string) { t.SetText("TPUB", publisher) }
+
+// Set the genre.
+// ID3v2.3 refers the known ID3v1 genres by index, like "(186)Podcast".
+func (t *ID3Tag) SetGenre(genre string) {
+	if idx, ok := id3v1Genres[strings.ToLower(genre)]; ok && t.version == ID3v23 {
+		genre = "(" + strconv.Itoa(int(idx)) + ")" + genre
+	}
+	t.SetText("TCON", genre)
+}
+
+// Get the genre without ID3v1 genre reference.
+func (t *ID3Tag) Genre() string {
+	genre := t.Text("TCON")
+	if strings.HasPrefix(genre, "(") {
+		if i := strings.IndexByte(genre, ')'); i > 0 && i < len(genre)-1 {
+			return genre[i+1:]
+		}
+	}
+	return genre
+}
+
+// Set the year of recording, TYER for ID3v2.3 and TDRC for ID3v2.4.
+func (t *ID3Tag) SetYear(year string) {
+	if t.version == ID3v24 {
+		t.SetText("TDRC", year)
+		return
+	}
+	t.SetText("TYER", year)
+	t.DeleteFrames("TDAT")
+	t.DeleteFrames("TIME")
+}
+
+// Set the full date of recording, TDRC for ID3v2.4 and TYER, TDAT and TIME for ID3v2.3.
+func (t *ID3Tag) SetDate(date time.Time) {
+	if t.version == ID3v24 {
+		t.SetText("TDRC", date.Format("2006-01-02T15:04:05"))
+		return
+	}
+	t.SetText("TYER", date.Format("2006"))
+	t.SetText("TDAT", date.Format("0201"))
+	t.SetText("TIME", date.Format("1504"))
+}
+
+// Get the year of recording.
+func (t *ID3Tag) Year() string {
+	if year := t.Text("TDRC"); len(year) >= 4 {
+		return year[:4]
+	}
+	return t.Text("TYER")
+}
+
+// Set the padding added to the tag when the whole file is rewritten.
+func (t *ID3Tag) SetPadding(padding int) {
+	t.padding = padding
+}
+
+// Write the tag to the file.
+// The tag is written in place if it fits the space of the original one, otherwise the whole file is rewritten
+// with the padding after the frames.
+func (t *ID3Tag) Write(path string) error {
+	frames := t.encodeFrames()
+	if int64(id3HeaderSize+len(frames)) <= t.origSize {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		buf := make([]byte, t.origSize)
+		t.putHeader(buf, int(t.origSize)-id3HeaderSize)
+		copy(buf[id3HeaderSize:], frames)
+		if _, err := f.WriteAt(buf, 0); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = src.Close()
+	}()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := src.Seek(t.origSize, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp := path + ".id3tmp"
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, id3HeaderSize+len(frames)+t.padding)
+	t.putHeader(buf, len(frames)+t.padding)
+	copy(buf[id3HeaderSize:], frames)
+	_, err = dst.Write(buf)
+	if err == nil {
+		_, err = io.Copy(dst, src)
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Write ID3v1 tag with the values of the ID3v2 tag to the end of the file.
+// Existing ID3v1 tag is overwritten. Non-latin characters are replaced with "?" since ID3v1 doesn't support them.
+func (t *ID3Tag) WriteV1(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	offset := fi.Size()
+	if offset >= id3v1Size {
+		var magic [3]byte
+		if _, err := f.ReadAt(magic[:], offset-id3v1Size); err == nil && string(magic[:]) == "TAG" {
+			offset -= id3v1Size
+		}
+	}
+
+	var buf [id3v1Size]byte
+	copy(buf[:3], "TAG")
+	putLatin1(buf[3:33], t.Text("TIT2"))
+	putLatin1(buf[33:63], t.Text("TPE1"))
+	putLatin1(buf[63:93], t.Text("TALB"))
+	putLatin1(buf[93:97], t.Year())
+	buf[127] = 255
+	if genre, ok := id3v1Genres[strings.ToLower(t.Genre())]; ok {
+		buf[127] = genre
+	}
+	if _, err := f.WriteAt(buf[:], offset); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Remove ID3v1 tag from the end of the file if it exists.
+func StripID3v1(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	if size := fi.Size(); size >= id3v1Size {
+		var magic [3]byte
+		if _, err := f.ReadAt(magic[:], size-id3v1Size); err == nil && string(magic[:]) == "TAG" {
+			if err := f.Truncate(size - id3v1Size); err != nil {
+				_ = f.Close()
+				return err
+			}
+		}
+	}
+	return f.Close()
+}
+
+// Encode all frames according to the tag version.
+func (t *ID3Tag) encodeFrames() []byte {
+	var buf bytes.Buffer
+	for _, fr := range t.frames {
+		var h [id3HeaderSize]byte
+		copy(h[:4], fr.ID)
+		if t.version == ID3v24 {
+			putSynchsafe(h[4:8], len(fr.Body))
+		} else {
+			binary.BigEndian.PutUint32(h[4:8], uint32(len(fr.Body)))
+		}
+		h[8], h[9] = fr.Flags[0], fr.Flags[1]
+		buf.Write(h[:])
+		buf.Write(fr.Body)
+	}
+	return buf.Bytes()
+}
+
+// Put the tag header with the size of the tag (excluding header) to the buffer.
+func (t *ID3Tag) putHeader(buf []byte, size int) {
+	copy(buf[:3], "ID3")
+	buf[3], buf[4], buf[5] = t.version, 0, 0
+	putSynchsafe(buf[6:10], size)
+}
+
+// Encode the text frame body.
+// ID3v2.4 uses UTF-8, ID3v2.3 uses ISO-8859-1 for latin text and UTF-16 for the rest.
+func encodeText(version byte, text string) []byte {
+	if version == ID3v24 {
+		return append([]byte{encUTF8}, text...)
+	}
+	latin := true
+	for _, r := range text {
+		if r > 0xff {
+			latin = false
+			break
+		}
+	}
+	if latin {
+		body := []byte{encISO88591}
+		for _, r := range text {
+			body = append(body, byte(r))
+		}
+		return body
+	}
+	body := []byte{encUTF16, 0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(text)) {
+		body = append(body, byte(u), byte(u>>8))
+	}
+	return body
+}
+
+// Decode the text frame body. Only the first value of multi-value frames is returned.
+func decodeText(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var text string
+	switch data := body[1:]; body[0] {
+	case encUTF16:
+		text = decodeUTF16(data, false)
+	case encUTF16BE:
+		text = decodeUTF16(data, true)
+	case encUTF8:
+		text = string(data)
+	default:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		text = string(runes)
+	}
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return text
+}
+
+// Decode UTF-16 string, BOM overrides the byte order.
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xff && data[1] == 0xfe:
+			bigEndian, data = false, data[2:]
+		case data[0] == 0xfe && data[1] == 0xff:
+			bigEndian, data = true, data[2:]
+		}
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// Decode the synchsafe integer: 4 bytes with 7 significant bits each.
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// Encode the synchsafe integer.
+func putSynchsafe(b []byte, n int) {
+	b[0], b[1], b[2], b[3] = byte(n>>21)&0x7f, byte(n>>14)&0x7f, byte(n>>7)&0x7f, byte(n)&0x7f
+}
+
+// Put the text as ISO-8859-1 into the fixed size field.
+func putLatin1(buf []byte, text string) {
+	i := 0
+	for _, r := range text {
+		if i >= len(buf) {
+			break
+		}
+		if r > 0xff {
+			r = '?'
+		}
+		buf[i] = byte(r)
+		i++
+	}
+}
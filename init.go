@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Interactively ask for the feeds and settings and write the config file.
+// Each feed is downloaded and parsed before adding it to the config.
+func Init(path string, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	ask := func(prompt, def string) (string, bool) {
+		if len(def) > 0 {
+			prompt += "[" + def + "] "
+		}
+		_, _ = fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			return "", false
+		}
+		if answer := strings.TrimSpace(scanner.Text()); len(answer) > 0 {
+			return answer, true
+		}
+		return def, true
+	}
+	askInt := func(prompt string, def int) (int, bool) {
+		for {
+			answer, ok := ask(prompt, strconv.Itoa(def))
+			if !ok {
+				return 0, false
+			}
+			if n, err := strconv.Atoi(answer); err == nil && n >= 0 {
+				return n, true
+			}
+			_, _ = fmt.Fprintln(out, msg("init.number"))
+		}
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		answer, ok := ask(msg("init.overwrite", path), "")
+		if !ok || !strings.EqualFold(answer, "y") {
+			return scanner.Err()
+		}
+	}
+
+	conf := &Config{Feeds: make([]*FeedConfig, 0)}
+	for {
+		url, ok := ask(msg("init.url"), "")
+		if !ok {
+			return scanner.Err()
+		}
+		if len(url) == 0 {
+			if len(conf.Feeds) == 0 {
+				continue
+			}
+			break
+		}
+		feed, err := fetchFeed(url)
+		if err != nil {
+			_, _ = fmt.Fprintln(out, msg("failed", err))
+			continue
+		}
+		_, _ = fmt.Fprintln(out, msg("init.found", feed.Title, len(feed.Items)))
+		name, ok := ask(msg("init.name"), sanitizeName(feed.Title))
+		if !ok {
+			return scanner.Err()
+		}
+		conf.Feeds = append(conf.Feeds, &FeedConfig{Name: name, URL: url})
+	}
+
+	var ok bool
+	if conf.Dir, ok = ask(msg("init.dir"), DefaultDir()); !ok {
+		return scanner.Err()
+	}
+	if conf.Threads, ok = askInt(msg("init.threads"), 4); !ok {
+		return scanner.Err()
+	}
+	days, ok := askInt(msg("init.retention"), 0)
+	if !ok {
+		return scanner.Err()
+	}
+	for _, feed := range conf.Feeds {
+		feed.DeletePlayed = days
+	}
+	if err := conf.init(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, msg("init.written", path))
+	return nil
+}
+
+// Download and parse the feed.
+func fetchFeed(url string) (*gofeed.Feed, error) {
+	r, err := openSource(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return gofeed.NewParser().Parse(r)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/koykov/glsdl/glsdltest"
+)
+
+// Make the downloader of the fake server feed archived to the temporary directory.
+func newTestGlsdl(t *testing.T, srv *glsdltest.Server, dir string) *Glsdl {
+	t.Helper()
+	conf := Config{Dir: dir, Feeds: []*FeedConfig{{Name: "Test", URL: srv.FeedURL()}}}
+	if err := conf.init(); err != nil {
+		t.Fatal(err)
+	}
+	dl := NewGlsdl(feedSource(conf.Feeds[0]), conf.Feeds[0], 2)
+	dl.out = io.Discard
+	return dl
+}
+
+// Read the files of the download directory except the state DB.
+func snapshot(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == StateFile {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestFetchDownloadsNewEpisodesOnce(t *testing.T) {
+	published := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	srv := glsdltest.NewServer("Test Show", glsdltest.Episodes(3, published)...)
+	defer srv.Close()
+	dir := t.TempDir()
+
+	dl := newTestGlsdl(t, srv, dir)
+	if err := dl.Process(); err != nil {
+		t.Fatal(err)
+	}
+	if dl.statDl != 3 || dl.statFail != 0 {
+		t.Fatalf("downloaded %d, failed %d, want 3 and 0", dl.statDl, dl.statFail)
+	}
+	before := snapshot(t, dir+ps+"Test")
+	if len(before) != 3 {
+		t.Fatalf("%d files, want 3 episodes", len(before))
+	}
+
+	// The next day a new episode is published, only it is downloaded.
+	srv.Add(glsdltest.Episode{GUID: "episode-004", Title: "Episode 004. Title 4", Published: published.Add(3 * 24 * time.Hour), Media: glsdltest.MP3(5000)})
+	dl = newTestGlsdl(t, srv, dir)
+	if err := dl.Process(); err != nil {
+		t.Fatal(err)
+	}
+	if dl.statDl != 1 {
+		t.Fatalf("downloaded %d, want the new episode only", dl.statDl)
+	}
+	for _, guid := range []string{"episode-001", "episode-002", "episode-003", "episode-004"} {
+		if n := srv.Requests("/media/" + guid + ".mp3"); n != 1 {
+			t.Errorf("%s requested %d times, want once", guid, n)
+		}
+	}
+	after := snapshot(t, dir+ps+"Test")
+	for name, data := range before {
+		if after[name] != data {
+			t.Errorf("%s changed by the second run", name)
+		}
+	}
+}
+
+func TestFetchRetriesFailedDownload(t *testing.T) {
+	srv := glsdltest.NewServer("Test Show", glsdltest.Episodes(1, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))...)
+	defer srv.Close()
+	srv.Fail("/media/episode-001.mp3", 503, 1)
+
+	dl := newTestGlsdl(t, srv, t.TempDir())
+	if err := dl.Process(); err != nil {
+		t.Fatal(err)
+	}
+	if dl.statDl != 1 || dl.statFail != 0 {
+		t.Fatalf("downloaded %d, failed %d, want the retry pass to succeed", dl.statDl, dl.statFail)
+	}
+	if n := srv.Requests("/media/episode-001.mp3"); n != 2 {
+		t.Fatalf("requested %d times, want 2", n)
+	}
+}
+
+func TestFetchQuarantinesCorruptFile(t *testing.T) {
+	srv := glsdltest.NewServer("Test Show", glsdltest.Episode{
+		GUID: "episode-001", Title: "Episode 001. Broken", Published: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Media: []byte("<html>Not Found</html>"),
+	})
+	defer srv.Close()
+	dir := t.TempDir()
+
+	dl := newTestGlsdl(t, srv, dir)
+	if err := dl.Process(); err != nil {
+		t.Fatal(err)
+	}
+	if dl.statProcess != 0 || dl.statFail != 1 {
+		t.Fatalf("processed %d, failed %d, want the corrupt file failed", dl.statProcess, dl.statFail)
+	}
+	e, _ := dl.state.Get("episode-001")
+	if len(e.Quarantined) == 0 || !dl.fileExists(e.Quarantined) {
+		t.Fatalf("quarantined file %q isn't kept", e.Quarantined)
+	}
+}
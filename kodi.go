@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/xml"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+)
+
+// Directory in the podcasts root containing Kodi artist information folders.
+const kodiArtistsDir = ".artists"
+
+// Kodi artist metadata.
+type nfoArtist struct {
+	XMLName   xml.Name `xml:"artist"`
+	Name      string   `xml:"name"`
+	Genre     string   `xml:"genre,omitempty"`
+	Biography string   `xml:"biography,omitempty"`
+	Thumb     string   `xml:"thumb,omitempty"`
+}
+
+// Profile writing Kodi album.nfo to the download directory (next to folder.jpg of the cover) and artist.nfo with thumb.jpg
+// to the artist information folder (~/Music/Podcast/.artists/<artist>), that should be set in Kodi music settings.
+type kodiProfile struct{}
+
+func (p kodiProfile) Export(dl *Glsdl) error {
+	album := dl.nfoAlbum()
+	if err := writeXML(dl.downloadDir+ps+"album.nfo", album); err != nil {
+		return err
+	}
+
+	// Kodi looks for JPEG artwork only, folder.jpg is written with the cover.
+	cover := dl.downloadDir + ps + CoverFile
+
+	if len(album.AlbumArtist) == 0 {
+		return nil
+	}
+	artistDir := filepath.Dir(dl.downloadDir) + ps + kodiArtistsDir + ps + sanitizeName(album.AlbumArtist)
+	if err := os.MkdirAll(artistDir, 0755); err != nil {
+		return err
+	}
+	artist := nfoArtist{
+		Name:      album.AlbumArtist,
+		Genre:     album.Genre,
+		Biography: album.Review,
+	}
+	if _, err := os.Stat(cover); err == nil {
+		if err := convertJPEG(cover, artistDir+ps+"thumb.jpg"); err != nil {
+			return err
+		}
+		artist.Thumb = "thumb.jpg"
+	}
+	return writeXML(artistDir+ps+"artist.nfo", artist)
+}
+
+// Convert the image (PNG or JPEG) to JPEG.
+func convertJPEG(src, dest string) error {
+	img, err := decodeImage(src)
+	if err != nil {
+		return err
+	}
+	return writeJPEG(img, dest)
+}
+
+// Decode the image file (PNG or JPEG).
+func decodeImage(filename string) (image.Image, error) {
+	in, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	img, _, err := image.Decode(in)
+	return img, err
+}
+
+// Write the image to JPEG file.
+func writeJPEG(img image.Image, dest string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, img, &jpeg.Options{Quality: 90}); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Base name of the file pointing to the newest episode, the extension is the one of the episode.
+const LatestFile = "latest"
+
+// Get the name of the latest file pointing to the episode file.
+func latestName(filename string) string {
+	return LatestFile + filepath.Ext(filename)
+}
+
+// Check if the file of the download directory is the latest file.
+func isLatestFile(name string) bool {
+	return strings.TrimSuffix(name, filepath.Ext(name)) == LatestFile
+}
+
+// Point the latest file to the newest downloaded episode.
+// The symlink is used if possible, otherwise the episode is copied.
+func (dl *Glsdl) updateLatest() error {
+	if dl.feed == nil {
+		return nil
+	}
+	newest, newestTime := "", time.Time{}
+	for _, item := range dl.feed.Items {
+		e, ok := dl.state.Get(itemKey(item))
+		if !ok {
+			continue
+		}
+		if !dl.fileExists(e.Filename) {
+			continue
+		}
+		if published := itemPublished(item); len(newest) == 0 || published.After(newestTime) {
+			newest, newestTime = e.Filename, published
+		}
+	}
+	if len(newest) == 0 {
+		return nil
+	}
+
+	// The previous latest file may have another extension.
+	entries, err := os.ReadDir(dl.downloadDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); isLatestFile(name) && name != latestName(newest) {
+			if err := os.Remove(dl.downloadDir + ps + name); err != nil {
+				return err
+			}
+		}
+	}
+
+	link := dl.downloadDir + ps + latestName(newest)
+	if fi, err := os.Lstat(link); err == nil {
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if target, _ := os.Readlink(link); target == newest {
+				return nil
+			}
+		} else if src, err := os.Stat(dl.downloadDir + ps + newest); err == nil && src.Size() == fi.Size() {
+			return nil
+		}
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	}
+	if err := os.Symlink(newest, link); err == nil {
+		return nil
+	}
+	return linkFile(dl.downloadDir+ps+newest, link)
+}
+
+// Place the file to another layout: hardlink it if -hardlink flag is set, otherwise or if linking fails
+// (e.g. another file system) copy it. The hardlinks share the tag updates of the original file.
+func linkFile(src, dest string) error {
+	if *hardlink {
+		if err := os.Link(src, dest); err == nil {
+			return nil
+		}
+	}
+	return copyFile(src, dest)
+}
+
+// Copy the file contents to the destination.
+// The reflink is tried first, the data is copied if the file system doesn't support it.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	if err := reflink(out, in); err == nil {
+		return out.Close()
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Page of the list, zero size means all episodes.
+type listPage struct {
+	Number int `json:"page,omitempty"`
+	Size   int `json:"page_size,omitempty"`
+}
+
+// Get the bounds of the page of n episodes and the number of pages.
+func (p listPage) bounds(n int) (from, to, pages int) {
+	if p.Size <= 0 || n == 0 {
+		return 0, n, 1
+	}
+	pages = (n + p.Size - 1) / p.Size
+	number := min(max(p.Number, 1), pages)
+	from = (number - 1) * p.Size
+	return from, min(from+p.Size, n), pages
+}
+
+// Print the page of the archived episodes, oldest first.
+// Porcelain format is one line per episode with tab-separated number, filename and played flag.
+func (dl *Glsdl) List(out io.Writer, page listPage) {
+	// Let the supporters find the donate link.
+	if !dl.porcelain && dl.feed != nil {
+		for _, f := range parseFunding(dl.feed.Extensions) {
+			_, _ = fmt.Fprintln(out, msg("list.funding", f))
+		}
+		if l, ok := parseLicense(dl.feed.Extensions); ok {
+			_, _ = fmt.Fprintln(out, msg("list.license", l))
+		} else if len(dl.feed.Copyright) > 0 {
+			_, _ = fmt.Fprintln(out, msg("list.license", dl.feed.Copyright))
+		}
+	}
+	archived := dl.archivedItems()
+	from, to, pages := page.bounds(len(archived))
+	for _, a := range archived[from:to] {
+		e, _ := dl.state.Get(itemKey(a.Item))
+		prefix, _ := dl.parseTitle(a.Item)
+		if dl.porcelain {
+			_, _ = fmt.Fprintf(out, "%s\t%s\t%t\n", porcelainField(prefix), porcelainField(a.Filename), e.Played != nil)
+			continue
+		}
+		line := "* " + e.Title
+		if e.Played != nil {
+			line += " [" + msg("list.played") + "]"
+		}
+		if e.Republished {
+			line += " [" + msg("list.republished") + "]"
+		}
+		if e.Verified {
+			line += " [" + msg("list.verified") + "]"
+		}
+		// Funding of the episode, like the donate link of the guest.
+		for _, f := range parseFunding(a.Item.Extensions) {
+			line += "\n  " + msg("list.funding", f)
+		}
+		if l, ok := parseLicense(a.Item.Extensions); ok {
+			line += "\n  " + msg("list.license", l)
+		}
+		_, _ = fmt.Fprintln(out, line)
+	}
+	if pages > 1 && !dl.porcelain {
+		_, _ = fmt.Fprintln(out, msg("list.page", from/page.Size+1, pages, len(archived)))
+	}
+}
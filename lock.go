@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Name of the lock file kept in the download directory while the feed is processed.
+const LockFile = ".glsdl.lock"
+
+// The download directory is locked by another process.
+var errLocked = errors.New("download directory is locked")
+
+// Maximum time to wait for the lock held by another run.
+const lockWait = 5 * time.Minute
+
+// Lock the download directory to prevent concurrent runs.
+// Returns errLocked if another process holds the lock.
+func (dl *Glsdl) lock() (unlock func(), err error) {
+	return lockFile(dl.downloadDir + ps + LockFile)
+}
+
+// Wait for the lock of the download directory held by another run, up to lockWait.
+func (dl *Glsdl) waitLock() (unlock func(), err error) {
+	deadline := time.Now().Add(lockWait)
+	for {
+		unlock, err = dl.lock()
+		if err != errLocked || time.Now().After(deadline) {
+			return unlock, err
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// Check if the command modifies the download directory and needs the lock.
+// Long commands like play take the lock only to update their episode, see updateEpisode.
+func lockRequired(cmd string) bool {
+	return cmd != "cast" && cmd != "play" && cmd != "list" && cmd != "check"
+}
+
+// Check if the command doesn't write to the archive, so it's allowed with -read-only flag.
+// Fetch is allowed too, it's replaced with check.
+func readOnlyCommand(cmd string) bool {
+	switch cmd {
+	case "", "fetch", "check", "list", "status", "export", "completion":
+		return true
+	}
+	return false
+}
+
+// Default lock implementation for the platforms without file locking: exclusively created file.
+// The file is left behind if the process is killed, so it must be removed manually.
+func createLockFile(path string) (unlock func(), err error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if os.IsExist(err) {
+		return nil, errLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = f.Close()
+	return func() {
+		_ = os.Remove(path)
+	}, nil
+}
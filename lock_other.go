@@ -0,0 +1,8 @@
+//go:build windows || plan9 || js || wasip1
+
+package main
+
+// Lock the file by creating it exclusively.
+func lockFile(path string) (unlock func(), err error) {
+	return createLockFile(path)
+}
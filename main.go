@@ -1,16 +1,20 @@
 package main
 
 import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/mikkyang/id3-go"
 	"github.com/mmcdole/gofeed"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
-	"os/user"
-	"regexp"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,179 +24,838 @@ import (
 const (
 	GlsFeed = "https://golangshow.com/index.xml"
 	ps      = string(os.PathSeparator)
+
+	// Default filename template, see itemFilename for the placeholders.
+	DefaultTemplate = "{number} - {title}"
+	// Filename template of "guid" mode, stable across renumbering and retitling.
+	GUIDTemplate = "{title} [{guid}]"
 )
 
+// Available commands, fetch is the default one.
+var commands = []string{"fetch", "migrate", "orphans", "cast", "play", "played", "sync", "list", "status", "daemon", "completion", "init", "retry", "auth", "check", "export", "import", "import-existing", "gaps", "get", "service", "add", "remove", "rename", "enable", "disable", "pause", "resume"}
+
 var (
-	threads = flag.Int("t", 4, "Threads to simultaneously download media files.")
+	threads      = flag.Int("t", 4, "Threads to simultaneously download media files, shared by all feeds.")
+	tagThreads   = flag.Int("tag-threads", runtime.NumCPU(), "Threads to simultaneously tag downloaded files, shared by all feeds.")
+	hostThreads  = flag.Int("host-threads", 2, "Maximum simultaneous downloads from one host, 0 means no limit.")
+	parallel     = flag.Int("parallel", 1, "Feeds to process concurrently.")
+	polite       = flag.Bool("polite", false, "Archive large back catalogs gently: 2 downloads per host, delays between the requests, conditional feed requests and descriptive User-Agent.")
+	reqDelay     = flag.Duration("request-delay", 0, "Delay between the requests to one host, 2s in -polite mode.")
+	userAgent    = flag.String("user-agent", "", "User-Agent of the requests, the descriptive one with -contact in -polite mode by default.")
+	contact      = flag.String("contact", "", "Contact URL or email added to the User-Agent in -polite mode, like mailto:me@example.com.")
+	adaptive     = flag.Bool("adaptive", false, "Adjust the number of active download threads up to -t by the throughput, backing off on timeouts and rate limiting.")
+	page         = flag.Int("page", 1, "Page of the list command.")
+	pageSize     = flag.Int("page-size", 50, "Episodes per page of the list command, 0 means all. Porcelain list isn't paginated unless -page is set.")
+	verbose      = flag.Bool("v", false, "Show the reasons of the skipped episodes.")
+	redownload   = flag.Bool("redownload", false, "Download the episodes with changed enclosure URL, length or publishing date again, keeping the old file as .bak.")
+	minDuration  = flag.Duration("min-duration", 0, "Skip the episodes shorter than this by itunes:duration, like 10m.")
+	maxDuration  = flag.Duration("max-duration", 0, "Skip the episodes longer than this by itunes:duration, like 2h.")
+	failWarn     = flag.Int("health-failures", 3, "Warn about the feed failed this number of daemon runs in a row, 0 disables it.")
+	dormant      = flag.Float64("dormant", 3, "Warn about the feed without new episodes for this number of its usual intervals between episodes, 0 disables it.")
+	tempDir      = flag.String("temp-dir", "", "Directory to download the episodes to before moving them to the library, temp_dir of the config by default.")
+	order        = flag.String("order", "", "Order of the download queue: newest, oldest, smallest or largest. The feed order by default.")
+	readOnly     = flag.Bool("read-only", false, "Verify and report against the shared archive without writing anything to it: fetch only checks the pending work.")
+	preflight    = flag.Bool("preflight", false, "Check existing files with HEAD request and download republished or truncated ones again.")
+	progressMode = flag.String("progress", "lines", "Progress display: lines (per episode), bar (overall progress line) or both.")
+	summary      = flag.Bool("summary", false, "Print the table of episodes sorted by number at the end instead of the line per episode.")
+	reportPath   = flag.String("report", "", "Write JSON report of the run to the file.")
+	monthQuota   = flag.String("monthly-quota", "", "Soft quota of the traffic per month, like 10G: no new downloads are started when it's exceeded.")
+	rate         = flag.String("rate", "", "Bandwidth cap of all downloads per second, like 1M.")
+	template     = flag.String("template", DefaultTemplate, "Filename template or \"guid\" for GUID-based names. Placeholders: {number}, {title}, {year}, {guid}.")
+	fuzzy        = flag.Bool("fuzzy", false, "Match episodes against manually renamed files before downloading.")
+	latest       = flag.Bool("latest", false, "Maintain "+LatestFile+".<ext> symlink to the newest episode.")
+	porcelain    = flag.Bool("porcelain", false, "Print stable tab-separated results (status, number, filename) for scripting.")
+	noColor      = flag.Bool("no-color", false, "Disable colored output.")
+	id3Ver       = flag.String("id3", "2.3", "ID3v2 version to write: 2.3 or 2.4.")
+	id3Pad       = flag.String("id3-padding", "4K", "Padding of the ID3v2 tag written to the new files, so the tag updates don't rewrite the whole file.")
+	id3v1        = flag.Bool("id3v1", false, "Also write ID3v1 tag.")
+	fsync        = flag.Bool("fsync", false, "Flush the written files and their directories to the storage, for NAS and USB drives.")
+	hardlink     = flag.Bool("hardlink", false, "Hardlink the episodes synced or copied to other directories on the same file system instead of copying them.")
+	dedup        = flag.Bool("dedup", false, "Keep one copy of the episodes cross-posted to several feeds, found by SHA-256 checksum.")
+	fundingTags  = flag.Bool("funding-tags", false, "Write podcast:funding link to WPAY frame and the license to TCOP and WCOP frames.")
+	strip        = flag.Bool("strip", false, "Wipe existing tags before writing new ones.")
+	mtime        = flag.Bool("mtime", false, "Set modification time of the files to the publishing date.")
+	atime        = flag.Bool("atime", false, "Also set access time of the files to the publishing date (requires -mtime).")
+	feedName     = flag.String("feed", "", "Process only the feed with this name, or the feed from the URL, file or stdin (-).")
+	device       = flag.String("device", "", "Cast device: DLNA renderer name (the first found by default) or cast://host[:port] for Chromecast.")
+	target       = flag.String("target", "", "Mount point of the device to sync the episodes to.")
+	transcode    = flag.String("transcode", "", "Bitrate to transcode the episodes to while syncing, like 64k (requires ffmpeg).")
+	quota        = flag.String("quota", "", "Maximum size of the episodes on the sync device, like 2G.")
+	profile      = flag.String("profile", "", "Comma-separated export profiles writing media server metadata: jellyfin, kodi, abs, html.")
+	langFlag     = flag.String("lang", "", "Language of the messages (en, ru). Detected from LANG by default.")
+	confPath     = flag.String("config", defaultConfigPath(), "Path to the config file.")
+	socket       = flag.String("socket", defaultSocketPath(), "Control socket of the daemon.")
+	interval     = flag.Duration("interval", time.Hour, "Interval between the daemon runs.")
+	dir          = flag.String("dir", "", "Directory containing the download directories of the feeds, overrides the config.")
+	proxy        = flag.String("proxy", "", "Proxy URL for all requests, HTTP_PROXY env var is used by default.")
+	debugAddr    = flag.String("debug-http", "", "Address to serve /debug/vars and /debug/workers endpoints on, like localhost:6060.")
+	profiling    = flag.Bool("pprof", false, "Serve /debug/pprof/ profiles on -debug-http address.")
+	otlpEndpoint = flag.String("otlp", "", "OTLP/HTTP endpoint to export the traces of the run to, like http://localhost:4318.")
+	healthAddr   = flag.String("http", "", "Address to serve /healthz endpoint of the daemon on, like :8080.")
+	tlsCA        = flag.String("ca", "", "PEM file with CA certificates trusted in addition to the system ones.")
+	tlsCert      = flag.String("cert", "", "PEM file with the client certificate (requires -key).")
+	tlsKey       = flag.String("key", "", "PEM file with the key of the client certificate.")
+	tlsMin       = flag.String("tls-min", "", "Minimum TLS version: 1.0, 1.1, 1.2 or 1.3.")
+	insecure     = flag.Bool("insecure", false, "Don't verify the server certificates. Use with care.")
+	h3           = flag.Bool("http3", false, "Try HTTP/3 (QUIC) first, falling back to HTTP/2 and HTTP/1.1 for the hosts not supporting it.")
+	dnsServer    = flag.String("dns", "", "DNS-over-HTTPS (https://host/dns-query) or DNS-over-TLS (tls://host) server for all requests.")
+	ipv4         = flag.Bool("4", false, "Connect over IPv4 only.")
+	ipv6         = flag.Bool("6", false, "Connect over IPv6 only.")
+	seedRatio    = flag.Float64("seed-ratio", 0, "Seed torrent enclosures until the uploaded data reaches the ratio of the file size.")
+	seedTime     = flag.Duration("seed-time", time.Hour, "Maximum time to keep seeding after the run.")
+	ytdlp        = flag.String("yt-dlp", "yt-dlp", "Path to yt-dlp extracting the audio of YouTube episodes.")
+	cookiesPath  = flag.String("cookies", "", "Cookie jar file in Netscape cookies.txt format, loaded before and saved after the run.")
 )
 
 // Main struct
 type Glsdl struct {
-	source       *io.ReadCloser
-	threads      int
-	waitGroup    sync.WaitGroup
-	parsePattern *regexp.Regexp
-	downloadDir  string
-	statDl       int
-	statProcess  int
-	statFail     int
-	statTime     time.Duration
+	source      Source
+	feed        *gofeed.Feed
+	conf        *FeedConfig
+	threads     int
+	waitGroup   sync.WaitGroup
+	titleParser TitleParser
+	numbering   map[string]string
+	// Suffixes of the filenames colliding with the ones of older items.
+	suffixes    map[string]string
+	downloadDir string
+	template    string
+	state       *State
+	fuzzyMatch  bool
+	fuzzy       fuzzyIndex
+	latest      bool
+	out         io.Writer
+	outMux      sync.Mutex
+	porcelain   bool
+	color       bool
+	id3Version  byte
+	id3Padding  int
+	id3v1       bool
+	strip       bool
+	mtime       bool
+	atime       bool
+	profiles    []Profile
+	pool        *pool
+	limiter     *rateLimiter
+	preflight   bool
+	progress    *progress
+	summary     bool
+	// Process only the episodes failed previously.
+	retry      bool
+	retryQueue []*gofeed.Item
+	failures   []Result
+	results    []Result
+	// Downloaded episodes with the transfer statistics.
+	transfers []Result
+	traffic   *trafficMeter
+	torrents  *torrentBackend
+	dedup     *dedupIndex
+	// Span of the feed processing.
+	trace span
+	// Write funding and license frames.
+	fundingTags bool
+	// Download republished episodes again.
+	redownload bool
+	// Order of the download queue.
+	order string
+	// Directory the episodes are downloaded to before moving them to the library.
+	tempDir string
+	// Gate of the daemon pausing the downloads.
+	gate *pauseGate
+	// Names of the files provided by the server by the enclosure URL.
+	serverNames map[string]string
+	// Episode requested by get command.
+	requested *gofeed.Item
+	// Duration limits of the episodes, zero means no limit.
+	minDuration time.Duration
+	maxDuration time.Duration
+	// Prefix of the output lines when the feeds are processed concurrently.
+	label       string
+	syncOpts    SyncOptions
+	mux         sync.Mutex
+	newFiles    []string
+	statDl      int
+	statProcess int
+	statFail    int
+	statTime    time.Duration
+	// Skipped episodes, up to maxSkips, and the numbers of them by the reason.
+	skips      []Result
+	skipCounts map[string]int
+	// Show the reasons of the skipped episodes.
+	verbose bool
 }
 
 // The constructor.
-// Takes source of a feed and maximum number of threads.
-func NewGlsdl(source *io.ReadCloser, threads int) *Glsdl {
-	usr, _ := user.Current()
+// Takes source of a feed, its settings and maximum number of threads.
+func NewGlsdl(source Source, conf *FeedConfig, threads int) *Glsdl {
 	dl := Glsdl{
-		source:       source,
-		threads:      threads,
-		parsePattern: regexp.MustCompile(`^[Выпуск|Episode]+\s+([[:alnum:]]+)\.*\s*(.*?)$`),
-		downloadDir:  strings.Join([]string{usr.HomeDir, "Music", "Podcast", "GolangShow"}, ps),
-		statDl:       0,
-		statProcess:  0,
-		statFail:     0,
+		source:      source,
+		conf:        conf,
+		threads:     threads,
+		titleParser: defaultTitleParser(conf.patterns),
+		downloadDir: conf.dir,
+		template:    DefaultTemplate,
+		id3Version:  ID3v23,
+		out:         os.Stdout,
+		statDl:      0,
+		statProcess: 0,
+		statFail:    0,
 	}
 
-	if _, err := os.Stat(dl.downloadDir); os.IsNotExist(err) {
+	if _, err := os.Stat(dl.downloadDir); os.IsNotExist(err) && !*readOnly {
 		_ = os.MkdirAll(dl.downloadDir, 0755)
 	}
 
+	state, err := LoadState(dl.downloadDir + ps + StateFile)
+	if err != nil {
+		log.Println(err)
+	}
+	dl.state = state
+
 	return &dl
 }
 
 // Main func to start the download process.
-func (dl *Glsdl) Process() {
+func (dl *Glsdl) Process() (err error) {
 	start := time.Now()
+	dl.trace = tracing.start(nil, "process", "feed", dl.conf.Name)
+	defer func() {
+		dl.trace.End(err)
+	}()
 
 	// Parse the feed.
-	parser := gofeed.NewParser()
-	feed, err := parser.Parse(*dl.source)
+	feed, err := dl.parseFeed()
 	if err != nil {
-		log.Fatal(err)
+		return classify(err, ErrParse)
 	}
 
-	fmt.Println("Progress:")
+	if !dl.porcelain && len(dl.label) == 0 {
+		dl.println(msg("progress"))
+	}
 
-	// Download the comver.
+	// Download the cover.
 	dl.waitGroup.Add(1)
 	go func() {
 		defer dl.waitGroup.Done()
-		filename := dl.downloadDir + ps + "cover.png"
-		if err := dl.downloadFile(feed.Image.URL, filename); err != nil {
+		// JSON Feed icon is optional.
+		ok, err := dl.downloadCover(feed)
+		if !ok {
+			return
+		}
+		if err != nil {
 			log.Println(err)
 		}
-		fmt.Println("* cover file")
+		if !dl.porcelain {
+			dl.println("* " + dl.label + msg("cover"))
+		}
+		dl.mux.Lock()
 		dl.statProcess++
+		dl.mux.Unlock()
 	}()
 
-	// Split feed to chunks according threads number param and process them simultaneously.
-	counter := 0
-	for _, item := range feed.Items {
-		counter++
-		dl.waitGroup.Add(1)
-		go dl.worker(item)
-		if counter >= dl.threads {
-			dl.waitGroup.Wait()
-			counter = 0
+	// Process the items simultaneously, the number of workers is limited by the pool.
+	if dl.pool == nil {
+		dl.pool = newPool(dl.threads, *tagThreads, 0)
+	}
+	if dl.progress == nil {
+		dl.progress = newProgress()
+	}
+	before := dl.progress.state()
+	dl.plan(feed.Items)
+	if planned := dl.progress.state(); !dl.porcelain && planned.TotalFiles > before.TotalFiles {
+		dl.println(dl.label + msg("plan", planned.TotalFiles-before.TotalFiles, formatSize(planned.TotalBytes-before.TotalBytes)))
+	}
+	dl.processItems(feed.Items, func(item *gofeed.Item) bool {
+		if !dl.retry {
+			return true
 		}
+		e, ok := dl.state.Get(itemKey(item))
+		return ok && len(e.Error) > 0
+	})
+	dl.waitGroup.Wait()
+	dl.retryFailed()
+	if dl.summary {
+		dl.printSummary()
 	}
-	if counter > 0 {
-		dl.waitGroup.Wait()
+
+	if err := dl.deletePlayed(); err != nil {
+		log.Println(err)
+	}
+	if err := dl.state.Save(); err != nil {
+		log.Println(err)
+	}
+	if dl.latest {
+		if err := dl.updateLatest(); err != nil {
+			log.Println(err)
+		}
+	}
+	for _, p := range dl.profiles {
+		if err := p.Export(dl); err != nil {
+			log.Println(err)
+		}
 	}
 
 	dl.statTime = time.Since(start)
+	return nil
+}
+
+// Process the items passing the filter by the workers of the feed.
+// The items are dispatched to the workers one at a time in the feed order, so no more than
+// the threads share of the feed's episodes is in progress at once. The filtered queue is built
+// and sorted only when -order is set.
+func (dl *Glsdl) processItems(list []*gofeed.Item, filter func(item *gofeed.Item) bool) {
+	if len(dl.order) > 0 {
+		queue := make([]*gofeed.Item, 0, len(list))
+		for _, item := range list {
+			if filter(item) {
+				queue = append(queue, item)
+			}
+		}
+		dl.orderItems(queue)
+		list, filter = queue, func(*gofeed.Item) bool { return true }
+	}
+	items := make(chan *gofeed.Item)
+	go func() {
+		defer close(items)
+		for i, item := range list {
+			if !filter(item) {
+				continue
+			}
+			dl.gate.wait(dl.conf.Name, func() []string {
+				titles := make([]string, 0)
+				for _, item := range list[i:] {
+					if filter(item) {
+						titles = append(titles, item.Title)
+					}
+				}
+				return titles
+			})
+			items <- item
+		}
+	}()
+	// Downloaded files are passed to the tagging workers, so the download slots are free for the next episodes.
+	jobs := make(chan *episodeJob)
+	var feedWorkers, taggers sync.WaitGroup
+	for i := 0; i < dl.pool.tagThreads(); i++ {
+		taggers.Add(1)
+		go func() {
+			defer taggers.Done()
+			for job := range jobs {
+				release := dl.pool.acquireTag()
+				dl.tagStage(job)
+				release()
+				dl.finish(job)
+			}
+		}()
+	}
+	for i := 0; i < max(dl.threads, 1); i++ {
+		feedWorkers.Add(1)
+		go func() {
+			defer feedWorkers.Done()
+			for item := range items {
+				release := dl.pool.acquire()
+				dl.waitGroup.Add(1)
+				job := dl.newJob(item, false)
+				tag := dl.fetchStage(job)
+				release()
+				if tag {
+					jobs <- job
+				} else {
+					dl.finish(job)
+				}
+			}
+		}()
+	}
+	feedWorkers.Wait()
+	close(jobs)
+	taggers.Wait()
 }
 
 // Build the statistics report.
 func (dl *Glsdl) Report() (report []string) {
 	report = make([]string, 0)
-	report = append(report, fmt.Sprintf("* %d files were downloaded", dl.statDl))
-	report = append(report, fmt.Sprintf("* %d files were processes", dl.statProcess))
-	report = append(report, fmt.Sprintf("* %d files were failed", dl.statFail))
-	report = append(report, fmt.Sprintf("* %s spent", dl.statTime))
+	report = append(report, "* "+msg("stat.downloaded", dl.statDl))
+	report = append(report, "* "+msg("stat.processed", dl.statProcess))
+	report = append(report, "* "+msg("stat.failed", dl.statFail))
+	if dl.verbose {
+		for _, line := range dl.skipReasons() {
+			report = append(report, "* "+msg("skipped")+" - "+line)
+		}
+	}
+	for _, res := range dl.failures {
+		line := "  - " + res.Title + ": "
+		if category := errorCategory(res.Err); len(category) > 0 {
+			line += "[" + category + "] "
+		}
+		report = append(report, line+res.Err.Error())
+	}
+	report = append(report, "* "+msg("stat.spent", dl.statTime))
 
 	return
 }
 
+// Episode passed from the download stage to the tagging one.
+type episodeJob struct {
+	item       *gofeed.Item
+	last       bool
+	start      time.Time
+	key        string
+	prefix     string
+	title      string
+	finalTitle string
+	filename   string
+	enclosure  media
+	download   bool
+	remote     remoteInfo
+	res        Result
+	ws         *workerState
+	sp         span
+}
+
 // Worker func. Takes feed item as param, download its media file and complete it with th ID3 tags.
-func (dl *Glsdl) worker(item *gofeed.Item) {
+// Items failed with retryable errors are queued for the retry pass unless it's the last attempt.
+func (dl *Glsdl) worker(item *gofeed.Item, last bool) Result {
+	job := dl.newJob(item, last)
+	if dl.fetchStage(job) {
+		release := dl.pool.acquireTag()
+		dl.tagStage(job)
+		release()
+	}
+	return dl.finish(job)
+}
+
+// Compose the title and output filename of the item.
+// The file recorded in the state DB is preferred, it keeps the previous name until migrate.
+func (dl *Glsdl) newJob(item *gofeed.Item, last bool) *episodeJob {
+	job := &episodeJob{item: item, last: last, start: time.Now(), key: itemKey(item)}
+	job.prefix, job.title = dl.parseTitle(item)
+	job.filename, job.finalTitle = dl.itemFilename(item)
+	job.res = Result{Number: job.prefix, Title: job.finalTitle, Filename: dl.relName(job.filename), item: item}
+	job.ws = workers.start(dl.conf.Name, job.finalTitle)
+	job.sp = tracing.start(dl.trace, "episode", "feed", dl.conf.Name, "episode", job.finalTitle)
+	return job
+}
+
+// Record the result of the episode processing.
+func (dl *Glsdl) finish(job *episodeJob) Result {
 	defer dl.waitGroup.Done()
-	if len(item.Enclosures[0].Length) == 0 {
+	defer workers.done(job.ws)
+	res := job.res
+	defer func() {
+		job.sp.End(res.Err)
+	}()
+	if res.Status == StatusFailed && !job.last && retryable(res.Err) {
+		dl.mux.Lock()
+		dl.retryQueue = append(dl.retryQueue, job.item)
+		dl.mux.Unlock()
+		return res
+	}
+	if res.Status == StatusFailed {
+		dl.mux.Lock()
+		dl.statFail++
+		varFailures.Add(1)
+		dl.failures = append(dl.failures, res)
+		dl.mux.Unlock()
+		dl.recordFailure(job.key, job.item, res.Err)
+	}
+	if res.Status == StatusSkipped {
+		dl.recordSkip(res)
+	}
+	res.Duration = time.Since(job.start)
+	if fi, err := os.Stat(dl.downloadDir + ps + res.Filename); err == nil && res.Status != StatusSkipped {
+		res.Size = fi.Size()
+	}
+	// Duplicates of the episodes kept by other feeds aren't new, same as for newFiles.
+	if res.Transfer > 0 && res.Status != StatusFailed && !res.hasOpt("dup") {
+		dl.mux.Lock()
+		dl.transfers = append(dl.transfers, res)
+		dl.mux.Unlock()
+	}
+	dl.printResult(res)
+	return res
+}
+
+// Download stage of the episode: check the state and download the media file if needed.
+// Returns true if the file should be tagged.
+func (dl *Glsdl) fetchStage(job *episodeJob) (tag bool) {
+	item, key, prefix, title, filename, finalTitle := job.item, job.key, job.prefix, job.title, job.filename, job.finalTitle
+	res, ws, sp := &job.res, job.ws, job.sp
+	defer func() {
+		job.filename = filename
+	}()
+
+	enclosure, ok := dl.itemMedia(item)
+	if !ok {
+		res.Status, res.Reason = StatusSkipped, SkipNoMedia
+		return
+	}
+	if reason := dl.filterReason(item); len(reason) > 0 {
+		res.Status, res.Reason = StatusSkipped, reason
 		return
 	}
 
-	// Parse the title.
-	prefix, title := dl.parseTitle(item)
-	finalTitle := "[" + prefix + "] " + title
+	if e, ok := dl.state.Get(key); ok && e.Deleted {
+		res.Status, res.Reason = StatusSkipped, SkipDeleted
+		return
+	} else if ok && e.duplicated() && !dl.fileExists(e.Filename) {
+		// The episode is kept by another feed.
+		res.Opts = append(res.Opts, "dup")
+		res.Status, res.Reason = StatusSkipped, SkipDuplicate
+		return
+	} else if ok {
+		if dl.fileExists(e.Filename) {
+			filename = dl.downloadDir + ps + e.Filename
+		}
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) && dl.fuzzyMatch {
+		if name, ok := dl.matchFile(prefix, title); ok {
+			filename = dl.downloadDir + ps + name
+			res.Opts = append(res.Opts, "fuzzy")
+		}
+	}
+	res.Filename = dl.relName(filename)
+	res.Status = StatusTagged
+	_, err := os.Stat(filename)
+	download := os.IsNotExist(err)
+	var restore func()
+	if e, ok := dl.state.Get(key); ok && !download && episodeUpdated(e, enclosure.Enclosure, item) {
+		// Republished episode is downloaded again if requested, the old file is kept as a backup.
+		res.Opts = append(res.Opts, "updated")
+		if dl.redownload {
+			if restore, err = backupFile(filename); err != nil {
+				res.Status, res.Err = StatusFailed, classify(err, ErrDisk)
+				return
+			}
+			download = true
+		}
+	}
+	if !download && dl.preflight && enclosure.Type != youtubeType && !isTorrent(enclosure.Enclosure) {
+		// Republished or truncated files are downloaded again.
+		e, _ := dl.state.Get(key)
+		if changed, err := dl.remoteChanged(e, filename, enclosure.URL); err == nil && changed {
+			download = true
+			res.Opts = append(res.Opts, "changed")
+		}
+	}
+	var remote remoteInfo
+	if download {
+		if ok, warn := dl.traffic.allow(); !ok {
+			// Monthly quota is exceeded, the episode waits for the next month.
+			if warn {
+				log.Println(msg("quota.exceeded", formatSize(dl.traffic.quota)))
+			}
+			if restore != nil {
+				restore()
+			}
+			res.Status, res.Reason = StatusSkipped, SkipQuota
+			return
+		}
+		res.Opts = append(res.Opts, "dl")
+		res.Status = StatusDownloaded
+		workers.setStage(ws, "downloading", enclosure.URL)
+		releaseHost := dl.pool.acquireHost(enclosure.URL)
+		transferStart := time.Now()
+		dsp := tracing.start(sp, "download", "url", enclosure.URL, "host", urlHost(enclosure.URL))
+		staged := dl.stagingFile(filename)
+		switch {
+		case isTorrent(enclosure.Enclosure):
+			remote, err = dl.downloadTorrent(enclosure.URL, staged)
+		case enclosure.Type == youtubeType:
+			remote, err = dl.downloadYouTube(enclosure.URL, staged)
+		case isVideo(enclosure.Enclosure) && dl.conf.AudioOnly:
+			// Only the audio track of the video is kept.
+			video := staged + videoExt(enclosure.Enclosure)
+			if remote, err = dl.downloadFile(enclosure.URL, video); err == nil {
+				err = classify(extractAudio(video, staged), ErrDisk)
+			}
+		default:
+			remote, err = dl.downloadFile(enclosure.URL, staged)
+		}
+		res.Transfer, res.Host = time.Since(transferStart), urlHost(enclosure.URL)
+		dsp.End(err)
+		releaseHost()
+		dl.pool.adaptive.observe(err, remote.Size, res.Transfer)
+		if err == nil && len(enclosure.Integrity) > 0 {
+			// Corrupted transfer is removed and retried like the interrupted one.
+			if err = verifyIntegrity(staged, enclosure.Integrity); err != nil {
+				_ = os.Remove(staged)
+				err = classify(err, ErrNetwork)
+			} else {
+				remote.Verified = true
+				res.Opts = append(res.Opts, "verified")
+			}
+		}
+		if err == nil {
+			err = classify(unstage(staged, filename), ErrDisk)
+		}
+		if err != nil {
+			if restore != nil {
+				restore()
+			}
+			res.Status, res.Err = StatusFailed, fmt.Errorf("download: %w", classify(err, ErrNetwork))
+			return
+		}
+		if fi, err := os.Stat(filename); err == nil {
+			dl.state.AddTraffic(fi.Size())
+			dl.traffic.add(fi.Size())
+		}
+		dl.progress.doneFiles.Add(1)
+		if dl.dedup != nil && restore == nil {
+			// The episode cross-posted to another feed is kept once.
+			if remote.Checksum, err = fileChecksum(filename); err != nil {
+				res.Status, res.Err = StatusFailed, classify(err, ErrDisk)
+				return
+			}
+			if original, ok := dl.dedup.claim(remote.Checksum, filename); ok {
+				if err := os.Remove(filename); err != nil {
+					res.Status, res.Err = StatusFailed, classify(err, ErrDisk)
+					return
+				}
+				dl.recordDuplicate(key, item, enclosure.Enclosure, finalTitle, original, remote)
+				res.Opts = append(res.Opts, "dup")
+				return
+			}
+		}
+		dl.mux.Lock()
+		dl.newFiles = append(dl.newFiles, filename)
+		dl.mux.Unlock()
+	}
+	job.enclosure, job.download, job.remote = enclosure, download, remote
+	return true
+}
 
-	opts := make([]string, 0)
+// Tagging stage of the episode, runs in its own slots so tag writes don't hold the download ones.
+func (dl *Glsdl) tagStage(job *episodeJob) {
+	item, key, prefix, filename, finalTitle := job.item, job.key, job.prefix, job.filename, job.finalTitle
+	enclosure, download, remote := job.enclosure, job.download, job.remote
+	res, ws, sp := &job.res, job.ws, job.sp
+	var err error
 
-	// Compose output filename and download it if needed.
-	filename := dl.downloadDir + ps + prefix + " - " + title + ".mp3"
-	if _, err := os.Stat(filename); os.IsNotExist(err) {
-		opts = append(opts, "dl")
-		err := dl.downloadFile(item.Enclosures[0].URL, filename)
+	// Video files, extracted audio and other audio formats are tagged with their native metadata.
+	workers.setStage(ws, "tagging", "")
+	tsp := tracing.start(sp, "tag", "file", res.Filename)
+	defer func() {
+		tsp.End(res.Err)
+	}()
+	if isContainerFile(filename) {
+		err = dl.writeContainerTags(filename, item, finalTitle, prefix)
+		if err == nil && dl.mtime {
+			err = dl.setFileTimes(filename, item)
+		}
+		if err == nil {
+			err = durable(filename)
+		}
 		if err != nil {
-			log.Println(err)
-			dl.statFail++
+			res.Status, res.Err = StatusFailed, fmt.Errorf("write tags: %w", classify(err, ErrTagging))
 			return
 		}
+		dl.recordDownload(key, item, enclosure.Enclosure, finalTitle, res.Filename, download, remote)
+		dl.mux.Lock()
+		dl.statProcess++
+		dl.mux.Unlock()
+		varProcessed.Add(1)
+		res.Opts = append(res.Opts, "tags")
+		return
 	}
 
-	// Open media file and complete it with ID3 tags.
-	tag, err := id3.Open(filename)
+	// Read ID3 tags of media file and complete it.
+	tag, err := ReadID3(filename)
+	if err == nil {
+		err = checkMPEG(filename, tag.origSize)
+	}
 	if err != nil {
-		log.Println(err)
-		dl.statFail++
+		// Broken file isn't kept as processed, it's downloaded again.
+		res.Status, res.Err = StatusFailed, fmt.Errorf("read tags: %w", classify(dl.corruptFile(key, filename, err), ErrTagging))
 		return
 	}
-	published, _ := time.Parse(time.RFC1123Z, item.Published)
+	if dl.strip {
+		tag.DeleteAll()
+		if !dl.id3v1 {
+			err = StripID3v1(filename)
+		}
+	}
+	tag.SetVersion(dl.id3Version)
+	tag.SetPadding(dl.id3Padding)
 	tag.SetTitle(finalTitle)
-	tag.SetArtist(item.Author.Name)
-	tag.SetAlbum("GolangShow")
-	tag.SetGenre("Technology")
-	tag.SetYear(strconv.Itoa(published.Year()))
-	defer func() {
-		_ = tag.Close()
-	}()
+	tag.SetArtist(dl.itemArtist(item))
+	tag.SetText("TPE2", dl.itemAlbumArtist())
+	tag.SetText("TRCK", trackNumber(prefix))
+	tag.SetAlbum(dl.itemAlbum(item))
+	tag.SetGenre(dl.itemGenre(item))
+	if date, ok := dl.itemDate(item); ok {
+		tag.SetDate(date)
+	} else {
+		tag.SetYear(dl.itemYear(item))
+	}
+	tag.SetPublisher(dl.itemPublisher(item))
+	tag.SetURL("WOAS", item.Link)
+	if dl.fundingTags {
+		dl.setFundingTags(tag, item)
+	}
+	if err == nil {
+		err = tag.Write(filename)
+	}
+	if err == nil && dl.id3v1 {
+		err = tag.WriteV1(filename)
+	}
+	if err == nil && dl.mtime {
+		err = dl.setFileTimes(filename, item)
+	}
+	if err == nil {
+		err = durable(filename)
+	}
+	if err != nil {
+		res.Status, res.Err = StatusFailed, fmt.Errorf("write tags: %w", classify(err, ErrTagging))
+		return
+	}
 
+	dl.recordDownload(key, item, enclosure.Enclosure, finalTitle, res.Filename, download, remote)
+	dl.mux.Lock()
 	dl.statProcess++
-	opts = append(opts, "id3")
+	dl.mux.Unlock()
+	varProcessed.Add(1)
+	res.Opts = append(res.Opts, "id3")
+	return
+}
 
-	fmt.Println("*", finalTitle, "["+strings.Join(opts, "+")+"]")
+// Replace the chain of parsers used to split titles to the number and title.
+func (dl *Glsdl) SetTitleParser(p TitleParser) {
+	dl.titleParser = p
 }
 
 // Parse the title of item and split it to the number and title.
+// Uses the fallback number if the number can't be parsed, the name of the file provided by the server
+// or the artist if there's no title.
 func (dl *Glsdl) parseTitle(item *gofeed.Item) (prefix, title string) {
-	res := dl.parsePattern.FindStringSubmatch(item.Title)
-	if len(res) == 0 {
-		return "", item.Title
+	prefix, title = dl.splitTitle(item)
+	if len(title) == 0 {
+		title = dl.serverName(item)
 	}
-	prefix, title = res[1], res[2]
 	if len(title) == 0 {
-		title = item.Author.Name
+		title = dl.itemArtist(item)
+	}
+	return
+}
+
+// Split the title of item to the number and title, the title may be empty.
+func (dl *Glsdl) splitTitle(item *gofeed.Item) (prefix, title string) {
+	prefix, title, ok := dl.titleParser.ParseTitle(item)
+	if !ok {
+		title = item.Title
+	}
+	if len(prefix) == 0 {
+		prefix = dl.fallbackNumber(item)
 	}
-	title = strings.Replace(title, ps, "_", -1)
 	return
 }
 
+// Compose the output filename and the final title of the item.
+// The filename is built from the template, available placeholders:
+// * {number} - episode number
+// * {title} - episode title
+// * {year} - year of publishing
+// * {guid} - short hash of the item GUID
+func (dl *Glsdl) itemFilename(item *gofeed.Item) (filename, finalTitle string) {
+	prefix, title := dl.parseTitle(item)
+	finalTitle = "[" + prefix + "] " + title
+	published := itemPublished(item)
+	template := dl.template
+	if template == "guid" {
+		template = GUIDTemplate
+	}
+	name := strings.NewReplacer(
+		"{number}", prefix,
+		"{title}", title,
+		"{year}", strconv.Itoa(published.Year()),
+		"{guid}", shortGUID(item),
+	).Replace(template)
+	filename = dl.downloadDir + ps + sanitizeName(name) + dl.suffixes[itemKey(item)] + dl.itemExt(item)
+	return
+}
+
+// Get the short hash of the item GUID, unique enough within the feed.
+func shortGUID(item *gofeed.Item) string {
+	sum := sha1.Sum([]byte(itemKey(item)))
+	return hex.EncodeToString(sum[:4])
+}
+
+// Get the publishing time of the item.
+func itemPublished(item *gofeed.Item) time.Time {
+	if item.PublishedParsed != nil {
+		return *item.PublishedParsed
+	}
+	published, _ := time.Parse(time.RFC1123Z, item.Published)
+	return published
+}
+
+// Set modification time (and access time if needed) of the file to the publishing date of the item.
+// Tagging modifies the file, so it should be called after it.
+func (dl *Glsdl) setFileTimes(filename string, item *gofeed.Item) error {
+	published := itemPublished(item)
+	if published.IsZero() {
+		return nil
+	}
+	accessed := time.Now()
+	if dl.atime {
+		accessed = published
+	}
+	return os.Chtimes(filename, accessed, published)
+}
+
+// Check if the file relative to the download directory exists.
+func (dl *Glsdl) fileExists(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	_, err := os.Stat(dl.downloadDir + ps + name)
+	return err == nil
+}
+
+// Get the filename relative to the download directory.
+func (dl *Glsdl) relName(filename string) string {
+	return strings.TrimPrefix(filename, dl.downloadDir+ps)
+}
+
+// Replace the characters that can't be used in filenames.
+func sanitizeName(name string) string {
+	return strings.Replace(name, ps, "_", -1)
+}
+
+// Parse the feed once and keep it for subsequent calls.
+func (dl *Glsdl) parseFeed() (*gofeed.Feed, error) {
+	if dl.feed != nil {
+		return dl.feed, nil
+	}
+	feed, err := dl.source.Fetch(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	dl.feed = feed
+	dl.buildNumbering(feed)
+	dl.buildCollisions(feed)
+	return feed, nil
+}
 
 // Download the file and report about any error.
-func (dl *Glsdl) downloadFile(url, dest string) (err error) {
+// Returns the size and Last-Modified header of the remote file.
+func (dl *Glsdl) downloadFile(url, dest string) (info remoteInfo, err error) {
 	fh, err := os.Create(dest)
 	if err != nil {
-		return err
+		return info, err
 	}
 	defer func() {
-		err := fh.Close()
+		if cerr := fh.Close(); cerr != nil {
+			log.Println(cerr)
+		}
+		// Partial file would be taken as downloaded by the next run.
 		if err != nil {
-			log.Println(err)
+			_ = os.Remove(dest)
 		}
 	}()
 
 	resp, err := http.Get(url)
 	if err != nil {
-		return err
+		return info, err
 	}
 	defer func() {
 		err := resp.Body.Close()
@@ -200,31 +863,633 @@ func (dl *Glsdl) downloadFile(url, dest string) (err error) {
 			log.Println(err)
 		}
 	}()
+	if resp.StatusCode != http.StatusOK {
+		return info, &httpStatusError{URL: url, Code: resp.StatusCode, Status: resp.Status}
+	}
+	if resp.ContentLength > 0 {
+		if err := preallocate(fh, resp.ContentLength); err != nil {
+			return info, err
+		}
+	}
 
-	_, err = io.Copy(fh, resp.Body)
+	var body io.Reader = workerReader{r: resp.Body, w: workers.byURL(url)}
+	if dl.progress != nil {
+		body = progressReader{r: body, p: dl.progress}
+	}
+	n, err := io.Copy(fh, limitReader(body, dl.limiter, dl.conf.limiter))
 	if err != nil {
-		return err
+		return info, err
 	}
+	if resp.ContentLength >= 0 && n < resp.ContentLength {
+		return info, fmt.Errorf("%s: truncated, %d of %d bytes: %w", url, n, resp.ContentLength, io.ErrUnexpectedEOF)
+	}
+	info = remoteInfo{Size: n, Modified: resp.Header.Get("Last-Modified")}
 
+	dl.mux.Lock()
 	dl.statDl++
+	dl.mux.Unlock()
+	varDownloads.Add(1)
 
-	return nil
+	return info, nil
 }
 
 func main() {
 	flag.Parse()
 
-	// Download the feed.
-	source, err := http.Get(GlsFeed)
+	// Flags are allowed after the command too.
+	cmd, service := flag.Arg(0), false
+	if flag.NArg() > 0 {
+		_ = flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if err := applyEnv(); err != nil {
+		log.Fatal(err)
+	}
+	// All commands and the files kept next to the config use the expanded path.
+	*confPath = expandHome(*confPath)
+	lang = detectLang(*langFlag)
+
+	var id3Version byte
+	switch *id3Ver {
+	case "2.3":
+		id3Version = ID3v23
+	case "2.4":
+		id3Version = ID3v24
+	default:
+		log.Fatal(msg("unknown_id3", *id3Ver))
+	}
+	if len(*proxy) > 0 {
+		u, err := url.Parse(*proxy)
+		if err != nil {
+			log.Fatal(err)
+		}
+		http.DefaultTransport.(*http.Transport).Proxy = http.ProxyURL(u)
+	}
+	tlsOpts := TLSOptions{CA: *tlsCA, Cert: *tlsCert, Key: *tlsKey, MinVersion: *tlsMin, Insecure: *insecure}
+	if err := tlsOpts.apply(); err != nil {
+		log.Fatal(err)
+	}
+	transportOpts := TransportOptions{HTTP3: *h3 && len(*proxy) == 0, DNS: *dnsServer}
+	switch {
+	case *ipv4 && *ipv6:
+		log.Fatal(msg("ip.both"))
+	case *ipv4:
+		transportOpts.Network = "tcp4"
+	case *ipv6:
+		transportOpts.Network = "tcp6"
+	}
+	transport, err := transportOpts.setup()
 	if err != nil {
-		log.Println(err)
+		log.Fatal(err)
+	}
+	if *polite {
+		// Conservative defaults, the flags lowering them are kept.
+		if *hostThreads <= 0 || *hostThreads > politeHostThreads {
+			*hostThreads = politeHostThreads
+		}
+		if !flagSet("request-delay") {
+			*reqDelay = politeDelay
+		}
+	}
+	if ua := requestUserAgent(*userAgent, *contact, *polite); len(ua) > 0 || *reqDelay > 0 {
+		transport = newPoliteTransport(transport, ua, *reqDelay)
+		http.DefaultClient.Transport = transport
+	}
+	if *readOnly && validCommand(cmd) && !readOnlyCommand(cmd) {
+		log.Fatal(msg("readonly.refused", cmd))
+	}
+	if *readOnly && (cmd == "" || cmd == "fetch") {
+		// Another machine mutates the archive, this one only shows what's to be done.
+		cmd = "check"
+	}
+	switch {
+	case cmd == "completion":
+		if err := writeCompletion(flag.Arg(0), os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case cmd == "init":
+		if err := Init(*confPath, os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case cmd == feedsCommand:
+		// Feed names for the completion scripts, errors are silenced.
+		if conf, err := LoadConfig(*confPath); err == nil {
+			for _, feed := range conf.Feeds {
+				fmt.Println(feed.Name)
+			}
+		}
+		return
+	case cmd == "add" || cmd == "remove" || cmd == "rename" || cmd == "enable" || cmd == "disable":
+		// Edit the subscriptions, the running daemon picks them up on SIGHUP.
+		switch cmd {
+		case "add":
+			err = AddFeed(*confPath, flag.Arg(0), flag.Arg(1), os.Stdout)
+		case "remove":
+			err = RemoveFeed(*confPath, flag.Arg(0), os.Stdout)
+		case "rename":
+			err = RenameFeed(*confPath, flag.Arg(0), flag.Arg(1), os.Stdout)
+		default:
+			err = EnableFeed(*confPath, flag.Arg(0), cmd == "enable", os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	case cmd == "service" && (flag.Arg(0) == "install" || flag.Arg(0) == "uninstall"):
+		// Register the daemon with the service manager of the OS.
+		if flag.Arg(0) == "install" {
+			err = installService()
+		} else {
+			err = uninstallService()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(msg("service."+flag.Arg(0)+"ed", serviceName))
+		return
+	case cmd == "service" && flag.Arg(0) == "run":
+		// The daemon started by the service manager.
+		cmd, service = "daemon", true
+	case cmd == "service":
+		log.Fatal(msg("service.usage"))
+	case len(cmd) > 0 && !validCommand(cmd):
+		log.Fatal(msg("unknown_command", cmd))
+	}
+	if !validOrder(*order) {
+		log.Fatal(msg("order.unknown", *order))
+	}
+	syncQuota, err := parseSize(*quota)
+	if err != nil {
+		log.Fatal(err)
+	}
+	monthlyQuota, err := parseSize(*monthQuota)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cmd == "sync" && len(*target) == 0 {
+		log.Fatal(msg("sync.notarget"))
 	}
 
-	// Process feed.
-	dl := NewGlsdl(&source.Body, *threads)
-	dl.Process()
+	// Let the running daemon do the work.
+	if daemonCommand(cmd) {
+		err := requestDaemon(*socket, daemonRequest{Cmd: cmd, Feed: *feedName, Page: listPageFlags()}, os.Stdout)
+		if err == nil {
+			return
+		}
+		if cmd == "status" || cmd == "pause" || cmd == "resume" {
+			log.Fatal(msg("daemon.none"))
+		}
+	}
 
-	// Display statistics.
-	fmt.Println("Statistics:")
-	fmt.Println(strings.Join(dl.Report(), "\n"))
+	exportProfiles, err := parseProfiles(*profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	rateLimit, err := parseSize(*rate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	id3Padding, err := parseSize(*id3Pad)
+	if err != nil {
+		log.Fatal(err)
+	}
+	opts := runOptions{id3Version: id3Version, id3Padding: int(id3Padding), syncQuota: syncQuota, monthlyQuota: monthlyQuota, profiles: exportProfiles, limiter: newRateLimiter(rateLimit)}
+	if len(*cookiesPath) > 0 {
+		if opts.cookies, err = loadCookieJar(expandHome(*cookiesPath)); err != nil {
+			log.Fatal(err)
+		}
+		http.DefaultClient.Jar = opts.cookies
+	}
+
+	conf, err := loadFeeds(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !flagSet("t") && conf.Threads > 0 {
+		*threads = conf.Threads
+	}
+	if !flagSet("temp-dir") && len(conf.TempDir) > 0 {
+		*tempDir = conf.TempDir
+	}
+	if len(*tempDir) > 0 && !*readOnly {
+		if err := os.MkdirAll(expandHome(*tempDir), 0755); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.auth, err = loadAuthStore(filepath.Dir(*confPath) + ps + TokensFile); err != nil {
+		log.Fatal(err)
+	}
+	opts.transport = newAuthTransport(transport)
+	http.DefaultClient.Transport = opts.transport
+	if len(*otlpEndpoint) > 0 {
+		if tracing, err = newOTLPTracer(*otlpEndpoint); err != nil {
+			log.Fatal(err)
+		}
+		defer func() {
+			if err := tracing.Shutdown(); err != nil {
+				log.Println(err)
+			}
+		}()
+	}
+	if cmd == "auth" {
+		// Authorize the private feeds.
+		for _, feed := range conf.Feeds {
+			if feed.Auth == nil && len(*feedName) == 0 {
+				continue
+			}
+			if err := opts.auth.Authorize(feed, os.Stdout); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+	if cmd == "export" || cmd == "import" {
+		// Move the episodes and the state DB to another machine.
+		if len(flag.Arg(0)) == 0 {
+			log.Fatal(msg("export.noarchive"))
+		}
+		if cmd == "export" {
+			err = Export(conf, expandHome(flag.Arg(0)), os.Stdout)
+		} else {
+			err = Import(conf, expandHome(flag.Arg(0)), os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *profiling && len(*debugAddr) == 0 {
+		log.Fatal(msg("pprof.noaddr"))
+	}
+	if len(*debugAddr) > 0 {
+		if err := serveDebug(*debugAddr, *profiling); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if cmd == "daemon" {
+		daemon := func() error {
+			return runDaemon(*socket, *healthAddr, *interval, conf, opts)
+		}
+		if service {
+			err = runService(daemon)
+		} else {
+			err = daemon()
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	// Exit code tells the category of the most severe error.
+	errs := make([]error, 0)
+	for _, r := range runFeeds(cmd, conf, opts, nil) {
+		if r.Err != nil && r.Err != errLocked {
+			errs = append(errs, r.Err)
+		}
+		if r.DL != nil {
+			for _, res := range r.DL.failures {
+				errs = append(errs, res.Err)
+			}
+		}
+	}
+	if code := exitCode(errs); code > 0 {
+		// Deferred calls don't run on exit.
+		if err := tracing.Shutdown(); err != nil {
+			log.Println(err)
+		}
+		os.Exit(code)
+	}
+}
+
+// Get the page of the list command, porcelain output is paginated only if the page is set explicitly.
+func listPageFlags() listPage {
+	if *porcelain && !flagSet("page") {
+		return listPage{}
+	}
+	return listPage{Number: *page, Size: *pageSize}
+}
+
+// Check if the flag was set in the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// Check if the command is known.
+func validCommand(cmd string) bool {
+	for _, c := range commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// Load the config and select the feeds to run the command against.
+func loadFeeds(cmd string) (*Config, error) {
+	conf, err := LoadConfig(*confPath)
+	if err != nil {
+		return nil, err
+	}
+	// Override the config by the flags and env vars.
+	if value, ok := os.LookupEnv(EnvPrefix + "FEEDS"); ok {
+		if conf.Feeds, err = parseEnvFeeds(value); err != nil {
+			return nil, err
+		}
+	}
+	if len(*dir) > 0 {
+		conf.Dir = *dir
+	}
+	if err := conf.init(); err != nil {
+		return nil, err
+	}
+	if len(*feedName) > 0 {
+		feeds, err := conf.filter(*feedName)
+		if err != nil && isSource(*feedName) {
+			var feed *FeedConfig
+			if feed, err = conf.sourceFeed(*feedName); err == nil {
+				feeds = []*FeedConfig{feed}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		conf.Feeds = feeds
+	} else {
+		feeds := conf.Feeds[:0]
+		for _, feed := range conf.Feeds {
+			if !feed.Disabled {
+				feeds = append(feeds, feed)
+			}
+		}
+		conf.Feeds = feeds
+	}
+	// Episode commands work with one feed.
+	if cmd == "cast" || cmd == "play" || cmd == "played" {
+		if len(conf.Feeds) == 0 {
+			return nil, errors.New(msg("feed.nomatch"))
+		}
+		conf.Feeds = conf.Feeds[:1]
+	}
+	return conf, nil
+}
+
+// Options of the run parsed from the flags.
+type runOptions struct {
+	id3Version byte
+	id3Padding int
+	syncQuota  int64
+	profiles   []Profile
+	pool       *pool
+	limiter    *rateLimiter
+	progress   *progress
+	cookies    *cookieJar
+	// Soft quota of the monthly traffic of all feeds.
+	monthlyQuota int64
+	traffic      *trafficMeter
+	torrents     *torrentBackend
+	dedup        *dedupIndex
+	gate         *pauseGate
+	auth         *authStore
+	transport    *authTransport
+	// Prefix the output lines with the feed name.
+	labeled bool
+}
+
+// Result of running the command against one feed.
+type feedRun struct {
+	Feed *FeedConfig
+	// Nil if the feed wasn't downloaded.
+	DL   *Glsdl
+	Err  error
+	Time time.Time
+}
+
+// Run the command against each feed and let MPD know about new episodes.
+// The errors are logged and reported in the results, the rest of the feeds are processed anyway.
+// Optional callback is called before processing each feed.
+// Fetch runs up to -parallel feeds concurrently, the workers pool is shared by them.
+func runFeeds(cmd string, conf *Config, opts runOptions, before func(feed *FeedConfig)) []feedRun {
+	runs := make([]feedRun, len(conf.Feeds))
+	opts.pool = newPool(*threads, *tagThreads, *hostThreads)
+	if *adaptive {
+		opts.pool.adaptive = newAdaptiveLimit(*threads)
+	}
+	opts.progress = newProgress()
+	opts.traffic = newTrafficMeter(opts.monthlyQuota, conf.Feeds)
+	opts.torrents = &torrentBackend{opts: TorrentOptions{SeedRatio: *seedRatio, SeedTime: *seedTime}}
+	defer opts.torrents.Close()
+	if *dedup {
+		opts.dedup = newDedupIndex(conf.Feeds)
+	}
+	if (cmd == "" || cmd == "fetch") && !*porcelain && *progressMode != "lines" && colorSupported(os.Stdout) {
+		opts.progress.bar, opts.progress.lines = true, *progressMode == "both"
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			opts.progress.render(os.Stdout, done)
+			close(stopped)
+		}()
+		defer func() {
+			close(done)
+			<-stopped
+		}()
+	}
+	concurrency := 1
+	if (cmd == "" || cmd == "fetch") && *parallel > 1 {
+		concurrency = *parallel
+		opts.labeled = len(conf.Feeds) > 1
+	}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i, feed := range conf.Feeds {
+		slots <- struct{}{}
+		if before != nil {
+			before(feed)
+		}
+		if len(conf.Feeds) > 1 && !*porcelain && !opts.labeled {
+			fmt.Println(msg("feed", feed.Name))
+		}
+		wg.Add(1)
+		go func(i int, feed *FeedConfig) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			r := feedRun{Feed: feed, Time: time.Now()}
+			r.DL, r.Err = runFeed(cmd, conf, feed, opts)
+			if r.Err != nil && r.Err != errLocked {
+				log.Println(r.Err)
+			}
+			runs[i] = r
+		}(i, feed)
+	}
+	wg.Wait()
+
+	if len(*reportPath) > 0 {
+		if err := writeReport(*reportPath, runs); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if opts.cookies != nil && !*readOnly {
+		if err := opts.cookies.Save(); err != nil {
+			log.Println(err)
+		}
+	}
+
+	newFiles := make([]string, 0)
+	notes := make([]episodeNote, 0)
+	for _, r := range runs {
+		if r.DL != nil {
+			newFiles = append(newFiles, r.DL.newFiles...)
+			notes = append(notes, r.DL.episodeNotes()...)
+		}
+	}
+	sendNotifications(conf.Notify, notes)
+
+	// Let MPD know about new episodes.
+	if conf.MPD != nil && len(newFiles) > 0 {
+		if err := notifyMPD(conf.MPD, newFiles); err != nil {
+			log.Println(err)
+		}
+	}
+	if len(newFiles) > 0 {
+		refreshMediaServers(conf.MediaServers)
+	}
+	return runs
+}
+
+// Download the feed and run the command against it.
+func runFeed(cmd string, conf *Config, feed *FeedConfig, opts runOptions) (*Glsdl, error) {
+	if feed.Auth != nil && opts.auth != nil {
+		token, err := opts.auth.token(feed)
+		if err != nil {
+			return nil, err
+		}
+		opts.transport.set(feed, token)
+	}
+
+	feedThreads := *threads
+	if feed.Threads > 0 {
+		feedThreads = feed.Threads
+	}
+	dl := NewGlsdl(feedSource(feed), feed, feedThreads)
+	dl.template = *template
+	if len(feed.Template) > 0 && !flagSet("template") {
+		dl.template = feed.Template
+	}
+	dl.fuzzyMatch = *fuzzy
+	dl.latest = *latest
+	dl.porcelain = *porcelain
+	dl.color = !*noColor && colorSupported(os.Stdout)
+	dl.id3Version = opts.id3Version
+	dl.id3Padding = opts.id3Padding
+	dl.id3v1 = *id3v1
+	dl.strip = *strip || feed.Strip
+	dl.mtime = *mtime
+	dl.atime = *atime
+	dl.profiles = opts.profiles
+	dl.pool = opts.pool
+	dl.limiter = opts.limiter
+	dl.traffic = opts.traffic
+	dl.torrents = opts.torrents
+	dl.dedup = opts.dedup
+	dl.gate = opts.gate
+	dl.fundingTags = *fundingTags
+	dl.redownload = *redownload
+	dl.preflight = *preflight
+	dl.order = *order
+	dl.verbose = *verbose
+	dl.tempDir = expandHome(*tempDir)
+	dl.minDuration, dl.maxDuration = *minDuration, *maxDuration
+	dl.progress = opts.progress
+	dl.summary = *summary
+	if opts.labeled {
+		dl.label = "[" + feed.Name + "] "
+	}
+	dl.syncOpts = SyncOptions{Target: *target, Bitrate: *transcode, Quota: opts.syncQuota}
+
+	// Skip the feed processed by another run.
+	if lockRequired(cmd) {
+		unlock, err := dl.lock()
+		if err == errLocked {
+			log.Println(msg("locked", feed.Name))
+			return dl, err
+		}
+		if err != nil {
+			return dl, err
+		}
+		defer unlock()
+	}
+	return dl, run(cmd, dl, conf)
+}
+
+// Keeps the statistics of concurrently processed feeds together.
+var statsMux sync.Mutex
+
+// Run the command against the feed.
+func run(cmd string, dl *Glsdl, conf *Config) error {
+	switch cmd {
+	case "", "fetch", "retry":
+		// Process feed.
+		dl.retry = cmd == "retry"
+		if err := dl.Process(); err != nil {
+			return err
+		}
+
+		// Display statistics.
+		if !dl.porcelain {
+			statsMux.Lock()
+			if len(dl.label) > 0 {
+				dl.println(msg("feed", dl.conf.Name))
+			}
+			dl.println(msg("statistics"))
+			dl.println(strings.Join(dl.Report(), "\n"))
+			statsMux.Unlock()
+		}
+	case "migrate":
+		// Rename existing files according to the current template.
+		return dl.Migrate(os.Stdout)
+	case "import-existing":
+		// Record the files downloaded before.
+		return dl.ImportExisting(expandHome(flag.Arg(0)), os.Stdout)
+	case "get":
+		// Download one episode.
+		return dl.Get(flag.Arg(0), os.Stdout)
+	case "gaps":
+		// Find the missing episodes.
+		return dl.Gaps(os.Stdin, os.Stdout)
+	case "orphans":
+		// Find and resolve orphan files.
+		return dl.ResolveOrphans(os.Stdin, os.Stdout)
+	case "cast":
+		// Stream the episode to LAN device.
+		return dl.Cast(flag.Arg(0), *device, os.Stdout)
+	case "play":
+		// Play the episode with local player.
+		return dl.Play(flag.Arg(0), conf.Player)
+	case "played":
+		return dl.MarkPlayed(flag.Arg(0))
+	case "check":
+		// Show the pending work.
+		return dl.Check(os.Stdout)
+	case "sync":
+		// Copy the episodes to the device.
+		return dl.Sync(dl.syncOpts, os.Stdout)
+	case "list":
+		// List archived episodes.
+		if _, err := dl.parseFeed(); err != nil {
+			return err
+		}
+		dl.List(os.Stdout, listPageFlags())
+	}
+	return nil
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Settings of the media server scanning the library after new episodes are downloaded.
+type MediaServerConfig struct {
+	// Server type: jellyfin (also Emby), plex or navidrome (any Subsonic API server).
+	Type string `json:"type"`
+	// Base URL of the server, like http://localhost:8096.
+	URL string `json:"url"`
+	// API key of Jellyfin, X-Plex-Token of Plex.
+	Token string `json:"token,omitempty"`
+	// Section ID of the Plex library containing the podcasts.
+	Library string `json:"library,omitempty"`
+	// Credentials of Navidrome user allowed to scan the library.
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Validate the media server settings.
+func (c *MediaServerConfig) init() error {
+	if len(c.URL) == 0 {
+		return fmt.Errorf("media server %s: url is required", c.Type)
+	}
+	switch c.Type {
+	case "jellyfin":
+		if len(c.Token) == 0 {
+			return fmt.Errorf("media server %s: token is required", c.Type)
+		}
+	case "plex":
+		if len(c.Token) == 0 || len(c.Library) == 0 {
+			return fmt.Errorf("media server %s: token and library are required", c.Type)
+		}
+	case "navidrome":
+		if len(c.User) == 0 {
+			return fmt.Errorf("media server %s: user is required", c.Type)
+		}
+	default:
+		return fmt.Errorf("unknown media server type %q", c.Type)
+	}
+	return nil
+}
+
+// Ask the media servers to scan the library, so new episodes appear without waiting for their periodic scans.
+// The errors are logged, the rest of the servers are asked anyway.
+func refreshMediaServers(servers []*MediaServerConfig) {
+	for _, s := range servers {
+		if err := s.refresh(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Start the library scan.
+func (c *MediaServerConfig) refresh() error {
+	base := strings.TrimRight(c.URL, "/")
+	var req *http.Request
+	var err error
+	switch c.Type {
+	case "jellyfin":
+		if req, err = http.NewRequest(http.MethodPost, base+"/Library/Refresh", nil); err == nil {
+			req.Header.Set("X-Emby-Token", c.Token)
+		}
+	case "plex":
+		req, err = http.NewRequest(http.MethodGet, base+"/library/sections/"+url.PathEscape(c.Library)+"/refresh", nil)
+		if err == nil {
+			req.Header.Set("X-Plex-Token", c.Token)
+		}
+	case "navidrome":
+		// Subsonic token authentication: md5 of the password and random salt.
+		salt := make([]byte, 8)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+		query := url.Values{"u": {c.User}, "s": {hex.EncodeToString(salt)}, "v": {"1.16.1"}, "c": {"glsdl"}, "f": {"json"}}
+		sum := md5.Sum([]byte(c.Password + query.Get("s")))
+		query.Set("t", hex.EncodeToString(sum[:]))
+		req, err = http.NewRequest(http.MethodGet, base+"/rest/startScan?"+query.Encode(), nil)
+	}
+	if err != nil {
+		return fmt.Errorf("media server %s: %w", c.Type, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("media server %s: %w", c.Type, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("media server %s: %w", c.Type, &httpStatusError{URL: base, Code: resp.StatusCode, Status: resp.Status})
+	}
+	if c.Type != "navidrome" {
+		return nil
+	}
+	// Subsonic API reports the errors in the body.
+	var body struct {
+		Response struct {
+			Status string `json:"status"`
+			Error  struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"subsonic-response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("media server %s: %w", c.Type, err)
+	}
+	if body.Response.Status != "ok" {
+		return fmt.Errorf("media server %s: %s", c.Type, body.Response.Error.Message)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Values of the ID3 tags.
+// The values are templates, see expandTag for the placeholders.
+type TagConfig struct {
+	Artist    string `json:"artist,omitempty"`
+	Album     string `json:"album,omitempty"`
+	Genre     string `json:"genre,omitempty"`
+	Year      string `json:"year,omitempty"`
+	Publisher string `json:"publisher,omitempty"`
+}
+
+// Get the artist of the item.
+// Configured override wins, then the fallback chain: item author, feed author, itunes:author of item and feed,
+// configured default.
+func (dl *Glsdl) itemArtist(item *gofeed.Item) string {
+	values := make([]string, 0, 6)
+	values = append(values, dl.expandTag(dl.conf.Tags.Artist, item))
+	if item.Author != nil {
+		values = append(values, item.Author.Name)
+	}
+	if dl.feed != nil && dl.feed.Author != nil {
+		values = append(values, dl.feed.Author.Name)
+	}
+	if item.ITunesExt != nil {
+		values = append(values, item.ITunesExt.Author)
+	}
+	if dl.feed != nil && dl.feed.ITunesExt != nil {
+		values = append(values, dl.feed.ITunesExt.Author)
+	}
+	values = append(values, dl.expandTag(dl.conf.Defaults.Artist, item))
+	return firstOf(values...)
+}
+
+// Get the album artist, the same for all episodes of the feed so media servers group them into one album.
+// Configured override wins, then the fallback chain: feed author, itunes:author of feed, configured default.
+func (dl *Glsdl) itemAlbumArtist() string {
+	var item *gofeed.Item
+	values := make([]string, 0, 4)
+	values = append(values, dl.expandTag(dl.conf.Tags.Artist, item))
+	if dl.feed != nil && dl.feed.Author != nil {
+		values = append(values, dl.feed.Author.Name)
+	}
+	if dl.feed != nil && dl.feed.ITunesExt != nil {
+		values = append(values, dl.feed.ITunesExt.Author)
+	}
+	values = append(values, dl.expandTag(dl.conf.Defaults.Artist, item))
+	return firstOf(values...)
+}
+
+// Get the album of the item.
+// Configured override wins, then the fallback chain: feed title, configured default, feed name.
+func (dl *Glsdl) itemAlbum(item *gofeed.Item) string {
+	values := make([]string, 0, 4)
+	values = append(values, dl.expandTag(dl.conf.Tags.Album, item))
+	if dl.feed != nil {
+		values = append(values, dl.feed.Title)
+	}
+	values = append(values, dl.expandTag(dl.conf.Defaults.Album, item), dl.conf.Name)
+	return firstOf(values...)
+}
+
+// Get the genre of the item.
+// Configured override wins, then the fallback chain: item category, itunes:category of feed, feed category,
+// configured default, "Podcast".
+func (dl *Glsdl) itemGenre(item *gofeed.Item) string {
+	values := make([]string, 0, 6)
+	values = append(values, dl.expandTag(dl.conf.Tags.Genre, item))
+	if len(item.Categories) > 0 {
+		values = append(values, item.Categories[0])
+	}
+	if dl.feed != nil && dl.feed.ITunesExt != nil && len(dl.feed.ITunesExt.Categories) > 0 {
+		values = append(values, dl.feed.ITunesExt.Categories[0].Text)
+	}
+	if dl.feed != nil && len(dl.feed.Categories) > 0 {
+		values = append(values, dl.feed.Categories[0])
+	}
+	values = append(values, dl.expandTag(dl.conf.Defaults.Genre, item), "Podcast")
+	return firstOf(values...)
+}
+
+// Get the year of the item.
+// Configured override wins, then the year of publishing, configured default.
+func (dl *Glsdl) itemYear(item *gofeed.Item) string {
+	year := ""
+	if published := itemPublished(item); !published.IsZero() {
+		year = strconv.Itoa(published.Year())
+	}
+	return firstOf(dl.expandTag(dl.conf.Tags.Year, item), year, dl.expandTag(dl.conf.Defaults.Year, item))
+}
+
+// Get the full publishing date of the item, if the year isn't overridden.
+func (dl *Glsdl) itemDate(item *gofeed.Item) (time.Time, bool) {
+	published := itemPublished(item)
+	return published, !published.IsZero() && len(dl.expandTag(dl.conf.Tags.Year, item)) == 0
+}
+
+// Get the publisher of the item.
+// Configured override wins, then the fallback chain: itunes:owner of feed, feed author, configured default.
+func (dl *Glsdl) itemPublisher(item *gofeed.Item) string {
+	values := make([]string, 0, 4)
+	values = append(values, dl.expandTag(dl.conf.Tags.Publisher, item))
+	if dl.feed != nil && dl.feed.ITunesExt != nil && dl.feed.ITunesExt.Owner != nil {
+		values = append(values, dl.feed.ITunesExt.Owner.Name)
+	}
+	if dl.feed != nil && dl.feed.Author != nil {
+		values = append(values, dl.feed.Author.Name)
+	}
+	values = append(values, dl.expandTag(dl.conf.Defaults.Publisher, item))
+	return firstOf(values...)
+}
+
+// Expand the placeholders of the tag template:
+// * {feed.title}, {feed.author} - title and author of the feed
+// * {feed.category} - itunes:category or category of the feed
+// * {item.author}, {item.category} - author and category of the item
+// * {number}, {title}, {year} - episode number, title and year of publishing
+// Item placeholders are empty for the values shared by all episodes, like album artist.
+func (dl *Glsdl) expandTag(value string, item *gofeed.Item) string {
+	if !strings.Contains(value, "{") {
+		return value
+	}
+	var feedTitle, feedAuthor, feedCategory, itemAuthor, itemCategory, number, title, year string
+	if feed := dl.feed; feed != nil {
+		feedTitle = feed.Title
+		if feed.Author != nil {
+			feedAuthor = feed.Author.Name
+		} else if feed.ITunesExt != nil {
+			feedAuthor = feed.ITunesExt.Author
+		}
+		if feed.ITunesExt != nil && len(feed.ITunesExt.Categories) > 0 {
+			feedCategory = feed.ITunesExt.Categories[0].Text
+		} else if len(feed.Categories) > 0 {
+			feedCategory = feed.Categories[0]
+		}
+	}
+	if item != nil {
+		if item.Author != nil {
+			itemAuthor = item.Author.Name
+		} else if item.ITunesExt != nil {
+			itemAuthor = item.ITunesExt.Author
+		}
+		if len(item.Categories) > 0 {
+			itemCategory = item.Categories[0]
+		}
+		number, title = dl.splitTitle(item)
+		if published := itemPublished(item); !published.IsZero() {
+			year = strconv.Itoa(published.Year())
+		}
+	}
+	return strings.TrimSpace(strings.NewReplacer(
+		"{feed.title}", feedTitle,
+		"{feed.author}", feedAuthor,
+		"{feed.category}", feedCategory,
+		"{item.author}", itemAuthor,
+		"{item.category}", itemCategory,
+		"{number}", number,
+		"{title}", title,
+		"{year}", year,
+	).Replace(value))
+}
+
+// Get the first non-empty value.
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return ""
+}
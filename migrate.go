@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Rename the existing files according to the current filename template and sanitization rules.
+// Uses the state DB mapping to find the files, so nothing is downloaded again.
+func (dl *Glsdl) Migrate(out io.Writer) (err error) {
+	feed, err := dl.parseFeed()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if serr := dl.state.Save(); serr != nil && err == nil {
+			err = serr
+		}
+	}()
+
+	renamed := 0
+	for _, item := range feed.Items {
+		key := itemKey(item)
+		// Failed episodes are recorded without the file.
+		e, ok := dl.state.Get(key)
+		if !ok || len(e.Filename) == 0 {
+			continue
+		}
+		filename, _ := dl.itemFilename(item)
+		name := dl.relName(filename)
+		if e.Filename == name {
+			continue
+		}
+		if _, err := os.Stat(dl.downloadDir + ps + e.Filename); os.IsNotExist(err) {
+			continue
+		}
+		if _, err := os.Stat(filename); err == nil {
+			_, _ = fmt.Fprintln(out, "*", msg("migrate.skipped", e.Filename, name))
+			continue
+		}
+
+		if err := os.Rename(dl.downloadDir+ps+e.Filename, filename); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(out, "*", e.Filename, "->", name)
+		e.Filename = name
+		dl.state.Put(key, e)
+		renamed++
+	}
+	_, _ = fmt.Fprintln(out, msg("migrate.renamed", renamed))
+
+	return nil
+}
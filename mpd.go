@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	mpdTimeout       = 30 * time.Second
+	mpdUpdateTimeout = 5 * time.Minute
+)
+
+// MPD integration settings.
+type MPDConfig struct {
+	// Host:port or path to the unix socket, localhost:6600 by default.
+	Address  string `json:"address,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Local path of MPD music_directory, used to compose URIs of the episodes.
+	MusicDir string `json:"music_dir"`
+	// Playlist to append new episodes to, nothing is appended if empty.
+	Playlist string `json:"playlist,omitempty"`
+}
+
+// Connection to MPD speaking its text protocol.
+type mpdConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Update MPD database of the directories of new episodes and append them to the playlist.
+func notifyMPD(conf *MPDConfig, files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	uris := make([]string, 0, len(files))
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		rel, err := filepath.Rel(conf.MusicDir, f)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("mpd: %s is outside of music directory %s", f, conf.MusicDir)
+		}
+		uri := filepath.ToSlash(rel)
+		uris = append(uris, uri)
+		dirs[filepath.ToSlash(filepath.Dir(rel))] = true
+	}
+
+	c, err := dialMPD(conf)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = c.conn.Close()
+	}()
+
+	for dir := range dirs {
+		if _, err := c.cmd("update", dir); err != nil {
+			return err
+		}
+	}
+	if len(conf.Playlist) == 0 {
+		return nil
+	}
+
+	// Songs can't be added to the playlist until the database update finishes.
+	deadline := time.Now().Add(mpdUpdateTimeout)
+	for {
+		status, err := c.cmd("status")
+		if err != nil {
+			return err
+		}
+		if !status["updating_db"] {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("mpd: database update timed out")
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	for _, uri := range uris {
+		if _, err := c.cmd("playlistadd", conf.Playlist, uri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Connect to MPD and authenticate if needed.
+func dialMPD(conf *MPDConfig) (*mpdConn, error) {
+	network, address := "tcp", conf.Address
+	if len(address) == 0 {
+		address = "localhost:6600"
+	}
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+	conn, err := net.DialTimeout(network, address, mpdTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &mpdConn{conn: conn, r: bufio.NewReader(conn)}
+	_ = conn.SetReadDeadline(time.Now().Add(mpdTimeout))
+	greeting, err := c.r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(greeting, "OK MPD") {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mpd: unexpected greeting %q: %v", greeting, err)
+	}
+	if len(conf.Password) > 0 {
+		if _, err := c.cmd("password", conf.Password); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Send the command and read the response.
+// Returns set of the response keys, enough to check the status flags.
+func (c *mpdConn) cmd(name string, args ...string) (map[string]bool, error) {
+	line := name
+	for _, a := range args {
+		line += ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+	}
+	_ = c.conn.SetDeadline(time.Now().Add(mpdTimeout))
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]bool)
+	for {
+		resp, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		resp = strings.TrimRight(resp, "\n")
+		switch {
+		case resp == "OK":
+			return keys, nil
+		case strings.HasPrefix(resp, "ACK "):
+			return nil, fmt.Errorf("mpd: %s: %s", name, resp[4:])
+		}
+		if i := strings.IndexByte(resp, ':'); i > 0 {
+			keys[resp[:i]] = true
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"strconv"
+)
+
+// Jellyfin (and Plex with XBMCnfo agent) album metadata.
+type nfoAlbum struct {
+	XMLName     xml.Name   `xml:"album"`
+	Title       string     `xml:"title"`
+	Artist      string     `xml:"artist,omitempty"`
+	AlbumArtist string     `xml:"albumartist,omitempty"`
+	Genre       string     `xml:"genre,omitempty"`
+	Year        string     `xml:"year,omitempty"`
+	Review      string     `xml:"review,omitempty"`
+	Outline     string     `xml:"outline,omitempty"`
+	Thumb       string     `xml:"thumb,omitempty"`
+	Tracks      []nfoTrack `xml:"track"`
+}
+
+// Track of the album metadata.
+type nfoTrack struct {
+	Position int    `xml:"position"`
+	Title    string `xml:"title"`
+}
+
+// Profile writing album.nfo recognized by Jellyfin and Plex, so each feed is shown as an album.
+type jellyfinProfile struct{}
+
+func (p jellyfinProfile) Export(dl *Glsdl) error {
+	return writeXML(dl.downloadDir+ps+"album.nfo", dl.nfoAlbum())
+}
+
+// Compose the album metadata of the feed.
+func (dl *Glsdl) nfoAlbum() nfoAlbum {
+	album := nfoAlbum{}
+	archived := dl.archivedItems()
+	if len(archived) > 0 {
+		first := archived[0].Item
+		album.Title = dl.itemAlbum(first)
+		album.Artist = dl.itemArtist(first)
+		album.AlbumArtist = dl.itemAlbumArtist()
+		album.Genre = dl.itemGenre(first)
+		album.Year = dl.itemYear(first)
+	}
+	if dl.feed != nil {
+		album.Review = dl.feed.Description
+		if dl.feed.ITunesExt != nil {
+			album.Outline = dl.feed.ITunesExt.Subtitle
+		}
+	}
+	if dl.fileExists(CoverFile) {
+		album.Thumb = CoverFile
+	}
+	for i, a := range archived {
+		_, title := dl.itemFilename(a.Item)
+		album.Tracks = append(album.Tracks, nfoTrack{Position: i + 1, Title: title})
+	}
+	return album
+}
+
+// Write the value as an indented XML document.
+func writeXML(filename string, v interface{}) error {
+	data, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	data = append(data, '\n')
+	return os.WriteFile(filename, data, 0644)
+}
+
+// Get the track number tag of the episode number, only numeric numbers are allowed.
+func trackNumber(number string) string {
+	if n, err := strconv.Atoi(number); err == nil && n > 0 {
+		return strconv.Itoa(n)
+	}
+	return ""
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the file keeping the pending digests, next to the config file.
+const NotifyStateFile = "notify.json"
+
+// Digest modes of the notifiers.
+const (
+	// Notification per episode.
+	DigestNone = ""
+	// One notification per run.
+	DigestRun = "run"
+	// One notification per day, the episodes are collected across the runs.
+	DigestDay = "day"
+)
+
+// Settings of the notifier.
+type NotifierConfig struct {
+	// Backend: webhook (JSON POST to the URL), ntfy, gotify, matrix, slack or discord.
+	Type string `json:"type"`
+	URL  string `json:"url"`
+	// Access token of ntfy, application token of Gotify.
+	Token string `json:"token,omitempty"`
+	// Matrix room ID.
+	Room string `json:"room,omitempty"`
+	// Topic of ntfy, the topics (Gotify application tokens, Matrix rooms) of the feeds by the feed name override it.
+	Topic  string            `json:"topic,omitempty"`
+	Topics map[string]string `json:"topics,omitempty"`
+	// Digest mode: empty for the notification per episode, run or day.
+	Digest string `json:"digest,omitempty"`
+}
+
+// Backend delivering the notifications.
+type notifier interface {
+	send(n notification) error
+}
+
+// New archived episode.
+type episodeNote struct {
+	Feed     string    `json:"feed"`
+	Title    string    `json:"title"`
+	Link     string    `json:"link,omitempty"`
+	Image    string    `json:"image,omitempty"`
+	Duration string    `json:"duration,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Notification of one or several episodes.
+type notification struct {
+	// Topic of the feeds of the episodes, see NotifierConfig.
+	Topic    string        `json:"topic,omitempty"`
+	Title    string        `json:"title"`
+	Text     string        `json:"text"`
+	Episodes []episodeNote `json:"episodes"`
+}
+
+// Create the notifier backend.
+func newNotifier(conf *NotifierConfig) (notifier, error) {
+	switch conf.Type {
+	case "webhook":
+		return webhookNotifier{url: conf.URL}, nil
+	case "ntfy":
+		url := conf.URL
+		if len(url) == 0 {
+			url = NtfyURL
+		}
+		return ntfyNotifier{url: url, token: conf.Token}, nil
+	case "gotify":
+		if len(conf.URL) == 0 {
+			return nil, fmt.Errorf("notifier %s: url is required", conf.Type)
+		}
+		return gotifyNotifier{url: conf.URL, token: conf.Token}, nil
+	case "matrix":
+		if len(conf.URL) == 0 || len(conf.Token) == 0 || len(conf.Room) == 0 {
+			return nil, fmt.Errorf("notifier %s: url, token and room are required", conf.Type)
+		}
+		return &matrixNotifier{homeserver: conf.URL, token: conf.Token, room: conf.Room}, nil
+	case "slack", "discord":
+		if len(conf.URL) == 0 {
+			return nil, fmt.Errorf("notifier %s: url is required", conf.Type)
+		}
+		if conf.Type == "slack" {
+			return slackNotifier{url: conf.URL}, nil
+		}
+		return discordNotifier{url: conf.URL}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier %q", conf.Type)
+}
+
+// Validate the notifier settings.
+func (c *NotifierConfig) init() error {
+	if _, err := newNotifier(c); err != nil {
+		return err
+	}
+	switch c.Digest {
+	case DigestNone, DigestRun, DigestDay:
+		return nil
+	}
+	return fmt.Errorf("notifier %s: unknown digest mode %q", c.Type, c.Digest)
+}
+
+// Get the topic of the feed.
+func (c *NotifierConfig) topic(feed string) string {
+	if topic, ok := c.Topics[feed]; ok {
+		return topic
+	}
+	return c.Topic
+}
+
+// Send the notifications of the episodes, grouped by the topics of their feeds.
+func (c *NotifierConfig) send(n notifier, notes []episodeNote) error {
+	topics := make([]string, 0)
+	groups := make(map[string][]episodeNote)
+	for _, note := range notes {
+		topic := c.topic(note.Feed)
+		if _, ok := groups[topic]; !ok {
+			topics = append(topics, topic)
+		}
+		groups[topic] = append(groups[topic], note)
+	}
+	for _, topic := range topics {
+		notification := newNotification(groups[topic])
+		notification.Topic = topic
+		if err := n.send(notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key of the notifier in the state of the digests.
+func (c *NotifierConfig) key() string {
+	return c.Type + " " + c.URL + " " + c.Topic
+}
+
+// Compose the notification of the episodes: the episode itself or the digest like "5 new episodes across 3 shows".
+func newNotification(notes []episodeNote) notification {
+	if len(notes) == 1 {
+		return notification{Title: msg("notify.episode", notes[0].Feed), Text: notes[0].Title, Episodes: notes}
+	}
+	feeds := make(map[string]bool)
+	lines := make([]string, 0, len(notes))
+	for _, n := range notes {
+		feeds[n.Feed] = true
+		lines = append(lines, n.Feed+": "+n.Title)
+	}
+	return notification{
+		Title:    msg("notify.digest", len(notes), len(feeds)),
+		Text:     strings.Join(lines, "\n"),
+		Episodes: notes,
+	}
+}
+
+// Pending episodes of the daily digests and the time they were sent last time.
+type notifyState struct {
+	Pending map[string][]episodeNote `json:"pending,omitempty"`
+	Sent    map[string]time.Time     `json:"sent,omitempty"`
+}
+
+// Send the notifications of the new episodes to all notifiers, according to their digest modes.
+func sendNotifications(confs []*NotifierConfig, notes []episodeNote) {
+	if len(confs) == 0 {
+		return
+	}
+	sort.SliceStable(notes, func(i, j int) bool { return notes[i].Time.Before(notes[j].Time) })
+	path := filepath.Dir(*confPath) + ps + NotifyStateFile
+	state := notifyState{Pending: make(map[string][]episodeNote), Sent: make(map[string]time.Time)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &state); err != nil {
+			log.Println(err)
+		}
+	}
+	changed := false
+	for _, conf := range confs {
+		n, err := newNotifier(conf)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		switch conf.Digest {
+		case DigestNone:
+			for _, note := range notes {
+				if err := conf.send(n, []episodeNote{note}); err != nil {
+					log.Println(err)
+				}
+			}
+		case DigestRun:
+			if err := conf.send(n, notes); err != nil {
+				log.Println(err)
+			}
+		case DigestDay:
+			key := conf.key()
+			pending := append(state.Pending[key], notes...)
+			changed = changed || len(notes) > 0
+			last := state.Sent[key]
+			if len(pending) == 0 || sameDay(last, time.Now()) {
+				state.Pending[key] = pending
+				continue
+			}
+			if err := conf.send(n, pending); err != nil {
+				// Try again by the next run.
+				log.Println(err)
+				state.Pending[key] = pending
+				continue
+			}
+			delete(state.Pending, key)
+			state.Sent[key] = time.Now()
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Check if the times are on the same local day.
+func sameDay(a, b time.Time) bool {
+	ya, ma, da := a.Local().Date()
+	yb, mb, db := b.Local().Date()
+	return ya == yb && ma == mb && da == db
+}
+
+// Collect the notes of the episodes downloaded by the feed run.
+func (dl *Glsdl) episodeNotes() []episodeNote {
+	notes := make([]episodeNote, 0, len(dl.transfers))
+	for _, res := range dl.transfers {
+		note := episodeNote{Feed: dl.conf.Name, Title: res.Title, Time: time.Now()}
+		if item := res.item; item != nil {
+			note.Link = item.Link
+			note.Time = itemPublished(item)
+			if item.Image != nil {
+				note.Image = item.Image.URL
+			}
+			if item.ITunesExt != nil {
+				note.Duration = item.ITunesExt.Duration
+			}
+		}
+		if len(note.Image) == 0 && dl.feed != nil && dl.feed.Image != nil {
+			note.Image = dl.feed.Image.URL
+		}
+		notes = append(notes, note)
+	}
+	return notes
+}
+
+// Notifier posting the notification as JSON to the URL.
+type webhookNotifier struct {
+	url string
+}
+
+func (w webhookNotifier) send(n notification) error {
+	return postJSON(w.url, n, nil)
+}
+
+// Public ntfy server.
+const NtfyURL = "https://ntfy.sh"
+
+// Notifier publishing to ntfy topic.
+type ntfyNotifier struct {
+	url   string
+	token string
+}
+
+func (nt ntfyNotifier) send(n notification) error {
+	if len(n.Topic) == 0 {
+		return errors.New("ntfy: topic isn't set")
+	}
+	message := map[string]interface{}{
+		"topic":   n.Topic,
+		"title":   n.Title,
+		"message": n.Text,
+	}
+	if len(n.Episodes) == 1 {
+		if e := n.Episodes[0]; len(e.Link) > 0 {
+			message["click"] = e.Link
+		}
+		if e := n.Episodes[0]; len(e.Image) > 0 {
+			message["icon"] = e.Image
+		}
+	}
+	header := http.Header{}
+	if len(nt.token) > 0 {
+		header.Set("Authorization", "Bearer "+nt.token)
+	}
+	return postJSON(strings.TrimSuffix(nt.url, "/"), message, header)
+}
+
+// Notifier sending Gotify messages. The topic is the application token.
+type gotifyNotifier struct {
+	url   string
+	token string
+}
+
+func (g gotifyNotifier) send(n notification) error {
+	token := n.Topic
+	if len(token) == 0 {
+		token = g.token
+	}
+	message := map[string]interface{}{
+		"title":    n.Title,
+		"message":  n.Text,
+		"priority": 5,
+	}
+	if len(n.Episodes) == 1 && len(n.Episodes[0].Link) > 0 {
+		message["extras"] = map[string]interface{}{
+			"client::notification": map[string]interface{}{"click": map[string]string{"url": n.Episodes[0].Link}},
+		}
+	}
+	header := http.Header{}
+	header.Set("X-Gotify-Key", token)
+	return postJSON(strings.TrimSuffix(g.url, "/")+"/message", message, header)
+}
+
+// Notifier posting the messages to Matrix room. The topic is the room ID.
+type matrixNotifier struct {
+	homeserver string
+	token      string
+	room       string
+}
+
+func (m *matrixNotifier) send(n notification) error {
+	room := n.Topic
+	if len(room) == 0 {
+		room = m.room
+	}
+	plain, formatted := n.Title, "<b>"+html.EscapeString(n.Title)+"</b>"
+	for _, e := range n.Episodes {
+		title := html.EscapeString(e.Title)
+		if len(e.Link) > 0 {
+			title = `<a href="` + html.EscapeString(e.Link) + `">` + title + "</a>"
+		}
+		plain += "\n" + e.Feed + ": " + e.Title
+		formatted += "<br>" + html.EscapeString(e.Feed) + ": " + title
+		if len(e.Link) > 0 {
+			plain += " " + e.Link
+		}
+	}
+	message := map[string]string{
+		"msgtype":        "m.text",
+		"body":           plain,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": formatted,
+	}
+	// Transaction ID makes the request idempotent.
+	txn := strconv.FormatInt(time.Now().UnixNano(), 10)
+	endpoint := strings.TrimSuffix(m.homeserver, "/") + "/_matrix/client/v3/rooms/" + url.PathEscape(room) +
+		"/send/m.room.message/" + txn
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+m.token)
+	return sendJSON(http.MethodPut, endpoint, message, header)
+}
+
+// Notifier posting to Slack incoming webhook: a section with the cover thumbnail per episode.
+type slackNotifier struct {
+	url string
+}
+
+// Maximum blocks of Slack message.
+const slackMaxBlocks = 50
+
+func (s slackNotifier) send(n notification) error {
+	blocks := []map[string]interface{}{{
+		"type": "header",
+		"text": map[string]string{"type": "plain_text", "text": n.Title},
+	}}
+	for _, e := range n.Episodes {
+		if len(blocks) == slackMaxBlocks {
+			break
+		}
+		title := slackEscape(e.Title)
+		if len(e.Link) > 0 {
+			title = "<" + e.Link + "|" + title + ">"
+		}
+		text := "*" + title + "*\n" + slackEscape(e.Feed)
+		if len(e.Duration) > 0 {
+			text += " · " + e.Duration
+		}
+		section := map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": text},
+		}
+		if len(e.Image) > 0 {
+			section["accessory"] = map[string]string{"type": "image", "image_url": e.Image, "alt_text": e.Feed}
+		}
+		blocks = append(blocks, section)
+	}
+	// The text is shown in the push notifications.
+	return postJSON(s.url, map[string]interface{}{"text": n.Title, "blocks": blocks}, nil)
+}
+
+// Escape the control characters of Slack markup.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// Notifier posting to Discord webhook: an embed with the cover thumbnail per episode.
+type discordNotifier struct {
+	url string
+}
+
+// Maximum embeds of Discord message.
+const discordMaxEmbeds = 10
+
+func (d discordNotifier) send(n notification) error {
+	embeds := make([]map[string]interface{}, 0, len(n.Episodes))
+	for _, e := range n.Episodes {
+		if len(embeds) == discordMaxEmbeds {
+			break
+		}
+		embed := map[string]interface{}{
+			"title":       e.Title,
+			"description": e.Feed,
+		}
+		if len(e.Link) > 0 {
+			embed["url"] = e.Link
+		}
+		if len(e.Image) > 0 {
+			embed["thumbnail"] = map[string]string{"url": e.Image}
+		}
+		if len(e.Duration) > 0 {
+			embed["fields"] = []map[string]interface{}{{"name": msg("notify.duration"), "value": e.Duration, "inline": true}}
+		}
+		if !e.Time.IsZero() {
+			embed["timestamp"] = e.Time.UTC().Format(time.RFC3339)
+		}
+		embeds = append(embeds, embed)
+	}
+	return postJSON(d.url, map[string]interface{}{"content": n.Title, "embeds": embeds}, nil)
+}
+
+// Post the value as JSON, the headers are added to the request.
+func postJSON(endpoint string, v interface{}, header http.Header) error {
+	return sendJSON(http.MethodPost, endpoint, v, header)
+}
+
+// Send the value as JSON with the method.
+func sendJSON(method, endpoint string, v interface{}, header http.Header) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, values := range header {
+		req.Header[k] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{URL: endpoint, Code: resp.StatusCode, Status: resp.Status}
+	}
+	return nil
+}
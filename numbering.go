@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Build the fallback numbers of the feed items which numbers can't be parsed.
+// Regular episodes are numbered by the publishing date (20060102), so the number doesn't change when new
+// episodes appear, or by the chronological position if the date is unknown.
+// Bonus episodes and trailers are numbered as specials: S0E1, S0E2, ...
+func (dl *Glsdl) buildNumbering(feed *gofeed.Feed) {
+	items := chronologicalItems(feed)
+	dl.numbering = make(map[string]string, len(items))
+	specials := 0
+	for i, item := range items {
+		if item.ITunesExt != nil && (item.ITunesExt.EpisodeType == "bonus" || item.ITunesExt.EpisodeType == "trailer") {
+			specials++
+			dl.numbering[itemKey(item)] = "S0E" + strconv.Itoa(specials)
+			continue
+		}
+		if published := itemPublished(item); !published.IsZero() {
+			dl.numbering[itemKey(item)] = published.Format("20060102")
+			continue
+		}
+		dl.numbering[itemKey(item)] = strconv.Itoa(i + 1)
+	}
+}
+
+// Get the items of the feed sorted from the oldest.
+func chronologicalItems(feed *gofeed.Feed) []*gofeed.Item {
+	// Feeds are usually sorted from the newest, so reverse it before the stable sort by date.
+	items := make([]*gofeed.Item, 0, len(feed.Items))
+	for i := len(feed.Items) - 1; i >= 0; i-- {
+		items = append(items, feed.Items[i])
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return itemPublished(items[i]).Before(itemPublished(items[j]))
+	})
+	return items
+}
+
+// Get the fallback number of the item.
+func (dl *Glsdl) fallbackNumber(item *gofeed.Item) string {
+	return dl.numbering[itemKey(item)]
+}
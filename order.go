@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Orders of the download queue, see -order flag. Empty order keeps the feed one.
+var downloadOrders = []string{"newest", "oldest", "smallest", "largest"}
+
+// Check if the download order is known.
+func validOrder(order string) bool {
+	if len(order) == 0 {
+		return true
+	}
+	for _, o := range downloadOrders {
+		if o == order {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort the items in the download order. The items without publishing date or enclosure length
+// are queued after the rest keeping the feed order.
+func (dl *Glsdl) orderItems(items []*gofeed.Item) {
+	var less func(a, b *gofeed.Item) bool
+	switch dl.order {
+	case "newest", "oldest":
+		less = func(a, b *gofeed.Item) bool {
+			pa, pb := itemPublished(a), itemPublished(b)
+			if pa.IsZero() || pb.IsZero() {
+				return !pa.IsZero() && pb.IsZero()
+			}
+			if dl.order == "newest" {
+				return pa.After(pb)
+			}
+			return pa.Before(pb)
+		}
+	case "smallest", "largest":
+		less = func(a, b *gofeed.Item) bool {
+			sa, sb := dl.itemLength(a), dl.itemLength(b)
+			if sa <= 0 || sb <= 0 {
+				return sa > 0 && sb <= 0
+			}
+			if dl.order == "smallest" {
+				return sa < sb
+			}
+			return sa > sb
+		}
+	default:
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+}
+
+// Get the enclosure length of the item, 0 if it's unknown.
+func (dl *Glsdl) itemLength(item *gofeed.Item) int64 {
+	m, ok := dl.itemMedia(item)
+	if !ok || m.Enclosure == nil {
+		return 0
+	}
+	size, _ := strconv.ParseInt(m.Enclosure.Length, 10, 64)
+	return size
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Matches the episode number prefix of the names composed by itemFilename.
+var orphanPrefix = regexp.MustCompile(`^([[:alnum:]]+)\s+-\s+`)
+
+// Extensions of the leftovers of the episode files: backups of the republished ones, see
+// BackupExt, and the staging files of the interrupted writes.
+var leftoverExts = []string{BackupExt, ".part", ".tmp"}
+
+// File in the download directory that doesn't correspond to any feed item or state DB record.
+type Orphan struct {
+	// Base name of the file.
+	Name string
+	// Feed item guessed by the episode number in the name, if any.
+	Match *gofeed.Item
+}
+
+// Find files in the download directory that don't correspond to any feed item or state DB record.
+func (dl *Glsdl) Orphans() ([]Orphan, error) {
+	feed, err := dl.parseFeed()
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the names of all expected files.
+	expected := map[string]bool{
+		CoverFile:          true,
+		"album.nfo":        true,
+		"folder.jpg":       true,
+		"thumb.jpg":        true,
+		"metadata.json":    true,
+		StateFile:          true,
+		StateFile + ".tmp": true,
+		LockFile:           true,
+		IndexFile:          true,
+		IndexFile + ".tmp": true,
+
+		// Copy of the feed for the conditional requests, see -polite flag.
+		FeedCacheFile:          true,
+		FeedCacheFile + ".tmp": true,
+	}
+	byPrefix := make(map[string]*gofeed.Item)
+	for _, item := range feed.Items {
+		filename, _ := dl.itemFilename(item)
+		expected[dl.relName(filename)] = true
+		expected[latestName(filename)] = true
+		if prefix, _ := dl.parseTitle(item); len(prefix) > 0 {
+			byPrefix[strings.ToLower(prefix)] = item
+		}
+	}
+
+	entries, err := os.ReadDir(dl.downloadDir)
+	if err != nil {
+		return nil, err
+	}
+	orphans := make([]Orphan, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || dl.knownFile(entry.Name(), expected) {
+			continue
+		}
+		orphan := Orphan{Name: entry.Name()}
+		if res := orphanPrefix.FindStringSubmatch(entry.Name()); len(res) > 0 {
+			orphan.Match = byPrefix[strings.ToLower(res[1])]
+		}
+		orphans = append(orphans, orphan)
+	}
+
+	return orphans, nil
+}
+
+// Check if the file is expected or recorded in the state DB, or is the leftover of such file.
+func (dl *Glsdl) knownFile(name string, expected map[string]bool) bool {
+	if expected[name] || dl.state.HasFilename(name) {
+		return true
+	}
+	for _, ext := range leftoverExts {
+		if base := strings.TrimSuffix(name, ext); base != name && (expected[base] || dl.state.HasFilename(base)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Interactively offer to adopt, rename or delete each orphan file.
+// Adopting renames the file to the name of the matched feed item, so it won't be downloaded again.
+func (dl *Glsdl) ResolveOrphans(in io.Reader, out io.Writer) error {
+	orphans, err := dl.Orphans()
+	if err != nil {
+		return err
+	}
+	if len(orphans) == 0 {
+		_, _ = fmt.Fprintln(out, msg("orphans.none"))
+		return nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	ask := func(prompt string) (string, bool) {
+		_, _ = fmt.Fprint(out, prompt)
+		if !scanner.Scan() {
+			return "", false
+		}
+		return strings.TrimSpace(scanner.Text()), true
+	}
+
+	for _, orphan := range orphans {
+		filename := dl.downloadDir + ps + orphan.Name
+		_, _ = fmt.Fprintln(out, "*", orphan.Name)
+
+		// Adopting is possible only if the matched item's file doesn't exist yet.
+		choices := msg("orphans.choices")
+		target := ""
+		if orphan.Match != nil {
+			var finalTitle string
+			target, finalTitle = dl.itemFilename(orphan.Match)
+			if _, err := os.Stat(target); os.IsNotExist(err) {
+				_, _ = fmt.Fprintln(out, "  "+msg("orphans.matches", finalTitle))
+				choices = msg("orphans.adopt") + choices
+			} else {
+				target = ""
+			}
+		}
+
+		answer, ok := ask("  " + choices)
+		if !ok {
+			return scanner.Err()
+		}
+		switch strings.ToLower(answer) {
+		case "a", "adopt":
+			if len(target) == 0 {
+				_, _ = fmt.Fprintln(out, "  "+msg("orphans.noadopt"))
+				continue
+			}
+			if err := os.Rename(filename, target); err != nil {
+				return err
+			}
+		case "r", "rename":
+			name, ok := ask("  " + msg("orphans.newname"))
+			if !ok {
+				return scanner.Err()
+			}
+			if len(name) == 0 {
+				continue
+			}
+			name = strings.Replace(name, ps, "_", -1)
+			if err := os.Rename(filename, dl.downloadDir+ps+name); err != nil {
+				return err
+			}
+		case "d", "delete":
+			if err := os.Remove(filename); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
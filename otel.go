@@ -0,0 +1,72 @@
+//go:build otel
+
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer exporting the spans to OpenTelemetry collector (Jaeger, Tempo) over OTLP/HTTP.
+type otelTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	once     sync.Once
+}
+
+type otelSpan struct {
+	ctx  context.Context
+	span trace.Span
+}
+
+// Create the tracer exporting to the endpoint, like http://localhost:4318.
+func newOTLPTracer(endpoint string) (tracer, error) {
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", "glsdl"))),
+	)
+	return &otelTracer{provider: provider, tracer: provider.Tracer("glsdl")}, nil
+}
+
+func (t *otelTracer) start(parent span, name string, attrs ...string) span {
+	ctx := context.Background()
+	if p, ok := parent.(*otelSpan); ok {
+		ctx = p.ctx
+	}
+	kv := make([]attribute.KeyValue, 0, len(attrs)/2)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		kv = append(kv, attribute.String(attrs[i], attrs[i+1]))
+	}
+	ctx, s := t.tracer.Start(ctx, name, trace.WithAttributes(kv...))
+	return &otelSpan{ctx: ctx, span: s}
+}
+
+// Flush the spans, the subsequent calls do nothing.
+func (t *otelTracer) Shutdown() (err error) {
+	t.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = t.provider.Shutdown(ctx)
+	})
+	return
+}
+
+func (s *otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,10 @@
+//go:build !otel
+
+package main
+
+import "errors"
+
+// OpenTelemetry SDK isn't included in the default build.
+func newOTLPTracer(_ string) (tracer, error) {
+	return nil, errors.New("tracing isn't supported by this build, build it with -tags otel")
+}
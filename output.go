@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Statuses of the processed items.
+// They are part of the porcelain output, so never change them.
+const (
+	StatusDownloaded = "downloaded"
+	StatusTagged     = "tagged"
+	StatusSkipped    = "skipped"
+	StatusFailed     = "failed"
+)
+
+// ANSI color codes of the statuses.
+var statusColors = map[string]string{
+	StatusDownloaded: "\x1b[32m",
+	StatusTagged:     "\x1b[32m",
+	StatusSkipped:    "\x1b[33m",
+	StatusFailed:     "\x1b[31m",
+}
+
+// Result of processing one feed item.
+type Result struct {
+	Status   string
+	Number   string
+	Title    string
+	Filename string
+	// Steps that were made: fuzzy, dl, id3.
+	Opts []string
+	Err  error
+	// Reason of the skip, like title.
+	Reason string
+	// Size of the file and time spent on the episode.
+	Size     int64
+	Duration time.Duration
+	// Host the file was downloaded from and time spent on the transfer.
+	Host     string
+	Transfer time.Duration
+	// Feed item of the episode.
+	item *gofeed.Item
+}
+
+// Get the average transfer speed in bytes per second.
+func (r Result) speed() float64 {
+	if r.Transfer <= 0 {
+		return 0
+	}
+	return float64(r.Size) / r.Transfer.Seconds()
+}
+
+// Check if the step was made.
+func (r Result) hasOpt(opt string) bool {
+	for _, o := range r.Opts {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// Print the result of processing the item.
+// Porcelain format is one line per item with tab-separated status, number and filename.
+func (dl *Glsdl) printResult(res Result) {
+	dl.outMux.Lock()
+	defer dl.outMux.Unlock()
+
+	if dl.porcelain {
+		_, _ = fmt.Fprintf(dl.out, "%s\t%s\t%s\n", res.Status, porcelainField(res.Number), porcelainField(res.Filename))
+		return
+	}
+	if dl.summary {
+		dl.results = append(dl.results, res)
+		return
+	}
+	// Only the failures are shown with the progress line alone.
+	p := dl.progress
+	if p != nil && p.bar && !p.lines && res.Status != StatusFailed {
+		return
+	}
+	opts := strings.Join(res.Opts, "+")
+	switch res.Status {
+	case StatusSkipped:
+		opts = msg("skipped")
+		if dl.verbose && len(res.Reason) > 0 {
+			opts += ": " + msg("skip."+res.Reason)
+		}
+	case StatusFailed:
+		opts = msg("failed", res.Err)
+	}
+	line := dl.label + res.Title + " [" + opts + "]"
+	if dl.color {
+		line = statusColors[res.Status] + line + "\x1b[0m"
+	}
+	if p != nil && p.bar {
+		p.println(dl.out, "* "+line)
+		return
+	}
+	_, _ = fmt.Fprintln(dl.out, "*", line)
+}
+
+// Print the line to the output, keeping the progress line below it.
+func (dl *Glsdl) println(line string) {
+	if p := dl.progress; p != nil && p.bar {
+		p.println(dl.out, line)
+		return
+	}
+	_, _ = fmt.Fprintln(dl.out, line)
+}
+
+// Check if the colored output may be used for the file.
+// Colors are disabled by NO_COLOR env var and for anything but terminal.
+func colorSupported(f *os.File) bool {
+	if len(os.Getenv("NO_COLOR")) > 0 || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Make the value safe to use as a porcelain field.
+func porcelainField(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+)
+
+// Name of the file keeping the paused state of the daemon and its pending queue, next to the config file.
+const PauseFile = "paused.json"
+
+// Gate of the daemon downloads: while it's paused no new downloads are dispatched, the ones
+// in progress complete. The paused state survives the daemon restart.
+type pauseGate struct {
+	path string
+	mux  sync.Mutex
+	cond *sync.Cond
+	// Pending episodes by the feed name while paused.
+	Paused  bool                `json:"paused"`
+	Pending map[string][]string `json:"pending,omitempty"`
+}
+
+// Load the paused state from the file. Missing file means not paused.
+func loadPauseGate(path string) *pauseGate {
+	g := &pauseGate{path: path, Pending: make(map[string][]string)}
+	g.cond = sync.NewCond(&g.mux)
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, g)
+	}
+	if g.Pending == nil {
+		g.Pending = make(map[string][]string)
+	}
+	return g
+}
+
+// Stop dispatching new downloads.
+func (g *pauseGate) pause() error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.Paused = true
+	return g.save()
+}
+
+// Resume the downloads.
+func (g *pauseGate) resume() error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	g.Paused = false
+	g.Pending = make(map[string][]string)
+	g.cond.Broadcast()
+	if err := os.Remove(g.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Get the paused state and the number of the pending episodes.
+func (g *pauseGate) state() (paused bool, pending int) {
+	if g == nil {
+		return false, 0
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	for _, titles := range g.Pending {
+		pending += len(titles)
+	}
+	return g.Paused, pending
+}
+
+// Block while paused. The remaining queue of the feed is recorded as pending until the resume.
+func (g *pauseGate) wait(feed string, queue func() []string) {
+	if g == nil {
+		return
+	}
+	g.mux.Lock()
+	defer g.mux.Unlock()
+	if !g.Paused {
+		return
+	}
+	g.Pending[feed] = queue()
+	if err := g.save(); err != nil {
+		log.Println(err)
+	}
+	for g.Paused {
+		g.cond.Wait()
+	}
+	delete(g.Pending, feed)
+}
+
+// Write the state to the file, the lock must be held.
+func (g *pauseGate) save() error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := g.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, g.path)
+}
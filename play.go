@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	playerPollInterval = 2 * time.Second
+	playerTimeout      = time.Second
+	// Episodes stopped closer than this to the end are considered finished.
+	playerEndGap = 10
+)
+
+// Local player settings.
+type PlayerConfig struct {
+	// Player executable: mpv (default), vlc or any other player taking the file as the last argument.
+	// Resume is supported for mpv and vlc only.
+	Command string   `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// Tracker of the playback position of the running player.
+type positionTracker interface {
+	// Get the position and duration in seconds.
+	Position() (position, duration float64, ok bool)
+	Close()
+}
+
+// Play the archived episode with the local player, resuming from the stored position.
+// The position is stored to the state DB when the player exits.
+func (dl *Glsdl) Play(query string, conf *PlayerConfig) error {
+	episode, err := dl.findEpisode(query)
+	if err != nil {
+		return err
+	}
+	key := itemKey(episode.Item)
+	e, _ := dl.state.Get(key)
+	filename := dl.downloadDir + ps + episode.Filename
+
+	command, args := "mpv", make([]string, 0)
+	if conf != nil && len(conf.Command) > 0 {
+		command = conf.Command
+	}
+	if conf != nil {
+		args = append(args, conf.Args...)
+	}
+	var tracker positionTracker
+	switch strings.TrimSuffix(filepath.Base(command), ".exe") {
+	case "mpv":
+		sock := filepath.Join(os.TempDir(), "glsdl-mpv-"+strconv.Itoa(os.Getpid()))
+		args = append(args, fmt.Sprintf("--start=%.0f", e.Position), "--input-ipc-server="+sock)
+		tracker = &mpvTracker{socket: sock}
+	case "vlc", "cvlc":
+		addr, err := freeLocalAddr()
+		if err != nil {
+			return err
+		}
+		args = append(args, fmt.Sprintf("--start-time=%.0f", e.Position), "--extraintf=rc", "--rc-host="+addr)
+		tracker = &vlcTracker{addr: addr}
+	}
+
+	cmd := exec.Command(command, append(args, filename)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	if tracker == nil {
+		return <-done
+	}
+	defer tracker.Close()
+
+	// Poll the position while the player runs.
+	position, duration := e.Position, 0.0
+	ticker := time.NewTicker(playerPollInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case err = <-done:
+			running = false
+		case <-ticker.C:
+			if pos, dur, ok := tracker.Position(); ok {
+				position, duration = pos, dur
+			}
+		}
+	}
+
+	// Finished episodes are marked as played and start from the beginning next time.
+	// The player doesn't hold the lock, so only the episode is updated in the current state DB.
+	uerr := dl.updateEpisode(key, func(e *Episode) {
+		if duration > 0 && position >= duration-playerEndGap {
+			now := time.Now()
+			e.Played, position = &now, 0
+		}
+		e.Position = position
+	})
+	if err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// Get the free local TCP address.
+func freeLocalAddr() (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	addr := ln.Addr().String()
+	return addr, ln.Close()
+}
+
+// Tracker querying mpv via JSON IPC socket.
+type mpvTracker struct {
+	socket string
+	conn   net.Conn
+	r      *bufio.Reader
+	reqID  int
+}
+
+func (t *mpvTracker) Position() (position, duration float64, ok bool) {
+	if t.conn == nil {
+		conn, err := net.DialTimeout("unix", t.socket, playerTimeout)
+		if err != nil {
+			return 0, 0, false
+		}
+		t.conn, t.r = conn, bufio.NewReader(conn)
+	}
+	position, ok = t.property("time-pos")
+	if !ok {
+		return 0, 0, false
+	}
+	duration, _ = t.property("duration")
+	return position, duration, true
+}
+
+// Get the numeric property, skipping the events sent by mpv.
+func (t *mpvTracker) property(name string) (float64, bool) {
+	t.reqID++
+	req, _ := json.Marshal(map[string]interface{}{"command": []string{"get_property", name}, "request_id": t.reqID})
+	_ = t.conn.SetDeadline(time.Now().Add(playerTimeout))
+	if _, err := t.conn.Write(append(req, '\n')); err != nil {
+		t.Close()
+		return 0, false
+	}
+	for {
+		line, err := t.r.ReadBytes('\n')
+		if err != nil {
+			t.Close()
+			return 0, false
+		}
+		var resp struct {
+			Data      *float64 `json:"data"`
+			Error     string   `json:"error"`
+			RequestID int      `json:"request_id"`
+		}
+		if json.Unmarshal(line, &resp) != nil || resp.RequestID != t.reqID {
+			continue
+		}
+		if resp.Error != "success" || resp.Data == nil {
+			return 0, false
+		}
+		return *resp.Data, true
+	}
+}
+
+func (t *mpvTracker) Close() {
+	if t.conn != nil {
+		_ = t.conn.Close()
+		t.conn = nil
+	}
+}
+
+// Tracker querying VLC via rc interface.
+type vlcTracker struct {
+	addr string
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (t *vlcTracker) Position() (position, duration float64, ok bool) {
+	if t.conn == nil {
+		conn, err := net.DialTimeout("tcp", t.addr, playerTimeout)
+		if err != nil {
+			return 0, 0, false
+		}
+		t.conn, t.r = conn, bufio.NewReader(conn)
+	}
+	position, ok = t.query("get_time")
+	if !ok {
+		return 0, 0, false
+	}
+	duration, _ = t.query("get_length")
+	return position, duration, true
+}
+
+// Send the command and read the first numeric line of the response.
+func (t *vlcTracker) query(cmd string) (float64, bool) {
+	_ = t.conn.SetDeadline(time.Now().Add(playerTimeout))
+	if _, err := t.conn.Write([]byte(cmd + "\n")); err != nil {
+		t.Close()
+		return 0, false
+	}
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			t.Close()
+			return 0, false
+		}
+		line = strings.TrimSpace(strings.TrimLeft(line, "> "))
+		if n, err := strconv.Atoi(line); err == nil {
+			return float64(n), true
+		}
+	}
+}
+
+func (t *vlcTracker) Close() {
+	if t.conn != nil {
+		_ = t.conn.Close()
+		t.conn = nil
+	}
+}
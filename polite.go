@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of -polite mode: downloads per host and the delay between the requests to one host.
+const (
+	politeHostThreads = 2
+	politeDelay       = 2 * time.Second
+)
+
+// User-Agent of -polite mode, the contact is added if set.
+const politeUserAgent = "glsdl (+https://github.com/koykov/glsdl)"
+
+// Transport sending User-Agent and spacing the requests to each host by the delay.
+// Retry-After of the throttled responses postpones the next requests to the host.
+type politeTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	delay     time.Duration
+	mux       sync.Mutex
+	next      map[string]time.Time
+}
+
+func newPoliteTransport(base http.RoundTripper, userAgent string, delay time.Duration) *politeTransport {
+	return &politeTransport{base: base, userAgent: userAgent, delay: delay, next: make(map[string]time.Time)}
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(t.userAgent) > 0 && len(req.Header.Get("User-Agent")) == 0 {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	host := strings.ToLower(req.URL.Host)
+	if t.delay > 0 {
+		t.mux.Lock()
+		at := t.next[host]
+		if now := time.Now(); at.Before(now) {
+			at = now
+		}
+		t.next[host] = at.Add(t.delay)
+		t.mux.Unlock()
+		if wait := time.Until(at); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-req.Context().Done():
+				timer.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			t.mux.Lock()
+			if at := time.Now().Add(after); at.After(t.next[host]) {
+				t.next[host] = at
+			}
+			t.mux.Unlock()
+		}
+	}
+	return resp, err
+}
+
+// Parse Retry-After header: delay in seconds or HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at), true
+	}
+	return 0, false
+}
+
+// Get User-Agent of the requests: the one set by -user-agent flag or the descriptive one with
+// the contact in -polite mode, empty means Go default.
+func requestUserAgent(userAgent, contact string, polite bool) string {
+	switch {
+	case len(userAgent) > 0:
+		return userAgent
+	case !polite:
+		return ""
+	case len(contact) > 0:
+		return strings.TrimSuffix(politeUserAgent, ")") + "; " + contact + ")"
+	}
+	return politeUserAgent
+}
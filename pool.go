@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/url"
+	"sync"
+)
+
+// Worker pool shared by the feeds: limits the total number of download and tagging workers
+// and the number of simultaneous downloads from one host.
+type pool struct {
+	slots    chan struct{}
+	tagSlots chan struct{}
+	perHost  int
+	mux      sync.Mutex
+	hosts    map[string]chan struct{}
+	// Limit of the active workers adjusted by the downloads, see -adaptive flag.
+	adaptive *adaptiveLimit
+}
+
+// Make the pool of the workers. Zero perHost means no per-host limit.
+func newPool(threads, tagThreads, perHost int) *pool {
+	return &pool{
+		slots:    make(chan struct{}, max(threads, 1)),
+		tagSlots: make(chan struct{}, max(tagThreads, 1)),
+		perHost:  perHost,
+		hosts:    make(map[string]chan struct{}),
+	}
+}
+
+// Get the number of the tagging slots.
+func (p *pool) tagThreads() int {
+	return cap(p.tagSlots)
+}
+
+// Take the worker slot, blocks while all of them are busy or the adaptive limit is reached.
+func (p *pool) acquire() (release func()) {
+	releaseActive := p.adaptive.acquire()
+	p.slots <- struct{}{}
+	return func() {
+		<-p.slots
+		releaseActive()
+	}
+}
+
+// Take the tagging slot, blocks while all of them are busy.
+func (p *pool) acquireTag() (release func()) {
+	p.tagSlots <- struct{}{}
+	return func() {
+		<-p.tagSlots
+	}
+}
+
+// Take the download slot of the URL host.
+func (p *pool) acquireHost(rawURL string) (release func()) {
+	host := urlHost(rawURL)
+	if p.perHost <= 0 || len(host) == 0 {
+		return func() {}
+	}
+	p.mux.Lock()
+	slots, ok := p.hosts[host]
+	if !ok {
+		slots = make(chan struct{}, p.perHost)
+		p.hosts[host] = slots
+	}
+	p.mux.Unlock()
+	slots <- struct{}{}
+	return func() {
+		<-slots
+	}
+}
+
+// Get the host of the URL, empty if it's invalid.
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Keep the file size while allocating the space.
+const fallocKeepSize = 0x01
+
+// Reserve the space of the file to be written, so the blocks are allocated contiguously
+// and lack of space fails the download before the transfer.
+// File systems not supporting fallocate are ignored.
+func preallocate(fh *os.File, size int64) error {
+	err := syscall.Fallocate(int(fh.Fd()), fallocKeepSize, 0, size)
+	if err == nil || errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return nil
+	}
+	return &os.PathError{Op: "fallocate", Path: fh.Name(), Err: err}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// Extend the file to be written to its size, so the file system may allocate the blocks contiguously.
+// The file is written from the start, the download fails if fewer bytes are received.
+func preallocate(fh *os.File, size int64) error {
+	return fh.Truncate(size)
+}
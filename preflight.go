@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Metadata of the remote file.
+type remoteInfo struct {
+	// Content length, -1 if unknown.
+	Size int64
+	// Last-Modified header.
+	Modified string
+	// Checksum of the downloaded file, if deduplication is enabled.
+	Checksum string
+	// Filename provided by the server, see responseName.
+	Name string
+	// The downloaded file matched podcast:integrity hash.
+	Verified bool
+}
+
+// Get the remote file metadata with HEAD request, ranged GET is used if HEAD isn't allowed.
+func headFile(url string) (remoteInfo, error) {
+	resp, err := http.Head(url)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		_ = resp.Body.Close()
+		return remoteInfo{Size: resp.ContentLength, Modified: resp.Header.Get("Last-Modified"), Name: responseName(resp)}, nil
+	}
+	if err == nil {
+		_ = resp.Body.Close()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return remoteInfo{}, err
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		return remoteInfo{}, err
+	}
+	_ = resp.Body.Close()
+	info := remoteInfo{Size: -1, Modified: resp.Header.Get("Last-Modified"), Name: responseName(resp)}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		cr := resp.Header.Get("Content-Range")
+		if i := strings.LastIndex(cr, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(cr[i+1:], 10, 64); err == nil {
+				info.Size = size
+			}
+		}
+	case http.StatusOK:
+		info.Size = resp.ContentLength
+	default:
+		return info, &httpStatusError{URL: url, Code: resp.StatusCode, Status: resp.Status}
+	}
+	return info, nil
+}
+
+// Check if the remote file differs from the downloaded one.
+// Size and Last-Modified are compared with the values recorded at the download time; without the record
+// the local file smaller than the remote one is considered as truncated. Tagging changes the size of
+// the local file, so it can't be compared with the remote one directly.
+func (dl *Glsdl) remoteChanged(e Episode, filename, url string) (bool, error) {
+	info, err := headFile(url)
+	if err != nil {
+		return false, err
+	}
+	if e.Size > 0 || len(e.Modified) > 0 {
+		return (e.Size > 0 && info.Size >= 0 && info.Size != e.Size) ||
+			(len(e.Modified) > 0 && len(info.Modified) > 0 && info.Modified != e.Modified), nil
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+	return info.Size > 0 && fi.Size() < info.Size, nil
+}
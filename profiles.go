@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Export profile writes media server specific metadata to the download directory after the run.
+type Profile interface {
+	Export(dl *Glsdl) error
+}
+
+// Registered export profiles by name.
+var profiles = map[string]Profile{
+	"jellyfin": jellyfinProfile{},
+	"kodi":     kodiProfile{},
+	"abs":      absProfile{},
+	"html":     htmlProfile{},
+}
+
+// Parse the comma-separated list of profile names.
+func parseProfiles(names string) ([]Profile, error) {
+	result := make([]Profile, 0)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		p, ok := profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// Archived episode: feed item with its local file.
+type archivedItem struct {
+	Item *gofeed.Item
+	// Filename relative to the download directory.
+	Filename string
+}
+
+// Get the archived episodes sorted chronologically.
+func (dl *Glsdl) archivedItems() []archivedItem {
+	result := make([]archivedItem, 0)
+	if dl.feed == nil {
+		return result
+	}
+	for _, item := range dl.feed.Items {
+		if e, ok := dl.state.Get(itemKey(item)); ok && dl.fileExists(e.Filename) {
+			result = append(result, archivedItem{Item: item, Filename: e.Filename})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return itemPublished(result[i].Item).Before(itemPublished(result[j].Item))
+	})
+	return result
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Progress of the run shared by the feeds: expected and transferred bytes and episodes.
+type progress struct {
+	start time.Time
+	// Overall progress line is displayed.
+	bar bool
+	// Per-episode lines are displayed too.
+	lines      bool
+	mux        sync.Mutex
+	drawn      bool
+	totalBytes atomic.Int64
+	doneBytes  atomic.Int64
+	totalFiles atomic.Int64
+	doneFiles  atomic.Int64
+}
+
+// Snapshot of the progress.
+type progressState struct {
+	DoneFiles, TotalFiles int64
+	DoneBytes, TotalBytes int64
+	// Bytes per second since the start.
+	Speed float64
+	// Estimated time left, zero if unknown.
+	ETA time.Duration
+}
+
+func newProgress() *progress {
+	return &progress{start: time.Now()}
+}
+
+// Add the episode expected to be downloaded.
+func (p *progress) expect(size int64) {
+	p.totalFiles.Add(1)
+	if size > 0 {
+		p.totalBytes.Add(size)
+	}
+}
+
+// Get the progress snapshot.
+func (p *progress) state() progressState {
+	s := progressState{
+		DoneFiles:  p.doneFiles.Load(),
+		TotalFiles: p.totalFiles.Load(),
+		DoneBytes:  p.doneBytes.Load(),
+		TotalBytes: p.totalBytes.Load(),
+	}
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		s.Speed = float64(s.DoneBytes) / elapsed
+	}
+	if s.Speed > 0 && s.TotalBytes > s.DoneBytes {
+		s.ETA = time.Duration(float64(s.TotalBytes-s.DoneBytes) / s.Speed * float64(time.Second))
+	}
+	return s
+}
+
+// Redraw the progress line until done is closed.
+func (p *progress) render(out io.Writer, done <-chan struct{}) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			p.mux.Lock()
+			p.draw(out)
+			_, _ = fmt.Fprintln(out)
+			p.drawn = false
+			p.mux.Unlock()
+			return
+		case <-ticker.C:
+			p.mux.Lock()
+			p.draw(out)
+			p.mux.Unlock()
+		}
+	}
+}
+
+// Print the line above the progress line.
+func (p *progress) println(out io.Writer, line string) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.drawn {
+		_, _ = fmt.Fprint(out, "\r\x1b[K")
+	}
+	_, _ = fmt.Fprintln(out, line)
+	if p.drawn {
+		p.draw(out)
+	}
+}
+
+// Draw the progress line in place of the previous one.
+func (p *progress) draw(out io.Writer) {
+	const width = 20
+	s := p.state()
+	filled := 0
+	if s.TotalBytes > 0 {
+		filled = int(min(s.DoneBytes, s.TotalBytes) * width / s.TotalBytes)
+	} else if s.TotalFiles > 0 {
+		filled = int(min(s.DoneFiles, s.TotalFiles) * width / s.TotalFiles)
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", width-filled)
+	eta := "?"
+	if s.ETA > 0 {
+		eta = s.ETA.Round(time.Second).String()
+	}
+	_, _ = fmt.Fprint(out, "\r\x1b[K["+bar+"] "+msg("progress.line", s.DoneFiles, s.TotalFiles,
+		formatSize(s.DoneBytes), formatSize(s.TotalBytes), formatSize(int64(s.Speed)), eta))
+	p.drawn = true
+}
+
+// Reader counting the transferred bytes.
+type progressReader struct {
+	r io.Reader
+	p *progress
+}
+
+func (r progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.doneBytes.Add(int64(n))
+	return n, err
+}
+
+// Compute the expected downloads of the items before processing them.
+// Enclosure length is used as the size, HEAD request is made if it's absent.
+func (dl *Glsdl) plan(items []*gofeed.Item) {
+	var wg sync.WaitGroup
+	for _, item := range items {
+		enclosure, ok := dl.itemMedia(item)
+		if !ok || !dl.wanted(item) || !dl.willDownload(item) {
+			continue
+		}
+		size, err := strconv.ParseInt(enclosure.Length, 10, 64)
+		if err == nil && size > 0 {
+			dl.progress.expect(size)
+			continue
+		}
+		wg.Add(1)
+		release := dl.pool.acquire()
+		go func(url string) {
+			defer func() {
+				release()
+				wg.Done()
+			}()
+			releaseHost := dl.pool.acquireHost(url)
+			info, _ := headFile(url)
+			releaseHost()
+			dl.progress.expect(info.Size)
+		}(enclosure.URL)
+	}
+	wg.Wait()
+}
+
+// Check if the item's file is missing, so it's going to be downloaded.
+func (dl *Glsdl) willDownload(item *gofeed.Item) bool {
+	if e, ok := dl.state.Get(itemKey(item)); ok {
+		if e.Deleted || e.duplicated() || dl.fileExists(e.Filename) {
+			return false
+		}
+	}
+	filename, _ := dl.itemFilename(item)
+	return !dl.fileExists(dl.relName(filename))
+}
+
+// Format the size in bytes for humans.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
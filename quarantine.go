@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Directory of the download directory keeping the corrupt media files.
+const QuarantineDir = "quarantine"
+
+// Media file can't be read or has no audio, it's quarantined and downloaded again.
+var errCorrupt = errors.New("corrupt media file")
+
+// Check if MPEG audio frame follows the ID3 tag at the offset: broken transfers and HTML error pages
+// served instead of the episode have none. Free-format and reserved values aren't valid headers.
+func checkMPEG(path string, offset int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, 64*1024)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	for i := 0; i+4 <= n; i++ {
+		h := buf[i : i+4]
+		if h[0] == 0xff && h[1]&0xe0 == 0xe0 && h[1]&0x18 != 0x08 && h[1]&0x06 != 0 &&
+			h[2]&0xf0 != 0xf0 && h[2]&0xf0 != 0 && h[2]&0x0c != 0x0c {
+			return nil
+		}
+	}
+	return errors.New("no MPEG audio frames")
+}
+
+// Check if the MP3 file would be quarantined by the tagging.
+func corruptMP3(filename string) bool {
+	tag, err := ReadID3(filename)
+	if err == nil {
+		err = checkMPEG(filename, tag.origSize)
+	}
+	return err != nil
+}
+
+// Move the corrupt file of the episode to the quarantine directory and record it in the state DB,
+// so the episode is downloaded again. The error of the last attempt is recorded by the failure.
+func (dl *Glsdl) quarantine(key, filename string, cause error) error {
+	dir := dl.downloadDir + ps + QuarantineDir
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	dest := dir + ps + filepath.Base(filename)
+	if err := moveFile(filename, dest); err != nil {
+		return err
+	}
+	dl.mux.Lock()
+	for i, name := range dl.newFiles {
+		if name == filename {
+			dl.newFiles = append(dl.newFiles[:i], dl.newFiles[i+1:]...)
+			break
+		}
+	}
+	dl.mux.Unlock()
+	e, _ := dl.state.Get(key)
+	e.Quarantined = dl.relName(dest)
+	dl.state.Put(key, e)
+	log.Println(msg("quarantined", dl.relName(filename), cause))
+	return nil
+}
+
+// Quarantine the file failed to be read, the error is returned as the corrupt file one.
+// Errors of opening the file don't mean it's corrupt and are kept as is.
+func (dl *Glsdl) corruptFile(key, filename string, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) && pathErr.Op == "open" {
+		return err
+	}
+	if qerr := dl.quarantine(key, filename, err); qerr != nil {
+		return fmt.Errorf("%w (quarantine: %v)", err, qerr)
+	}
+	return fmt.Errorf("%w: %w", errCorrupt, err)
+}
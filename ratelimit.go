@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Bandwidth limiter: token bucket with one second burst, shared by the readers.
+// Nil limiter means no limit.
+type rateLimiter struct {
+	// Bytes per second.
+	rate   float64
+	mux    sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// Make the limiter of the rate in bytes per second, nil if rate isn't positive.
+func newRateLimiter(rate int64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// Wait until n bytes may be transferred.
+// The tokens may go negative, so the concurrent readers wait in turn.
+func (l *rateLimiter) wait(n int) {
+	if l == nil {
+		return
+	}
+	l.mux.Lock()
+	now := time.Now()
+	l.tokens = min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate) - float64(n)
+	l.last = now
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mux.Unlock()
+	time.Sleep(delay)
+}
+
+// Reader limited by the limiters.
+type limitedReader struct {
+	r        io.Reader
+	limiters []*rateLimiter
+}
+
+// Wrap the reader with the limiters, nil ones are ignored.
+func limitReader(r io.Reader, limiters ...*rateLimiter) io.Reader {
+	active := make([]*rateLimiter, 0, len(limiters))
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &limitedReader{r: r, limiters: active}
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	// Small reads keep the rate smooth.
+	if len(p) > 32<<10 {
+		p = p[:32<<10]
+	}
+	n, err := r.r.Read(p)
+	for _, l := range r.limiters {
+		l.wait(n)
+	}
+	return n, err
+}
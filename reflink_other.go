@@ -0,0 +1,13 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Reflinks aren't supported, the files are copied.
+func reflink(dest, src *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+)
+
+// JSON report of the run.
+type runReport struct {
+	Time  time.Time    `json:"time"`
+	Feeds []feedReport `json:"feeds"`
+	// Transfer statistics of the hosts, the slowest first.
+	Hosts []hostReport `json:"hosts,omitempty"`
+}
+
+// Report of one feed.
+type feedReport struct {
+	Name       string           `json:"name"`
+	Error      string           `json:"error,omitempty"`
+	Category   string           `json:"category,omitempty"`
+	Downloaded int              `json:"downloaded"`
+	Processed  int              `json:"processed"`
+	Failed     int              `json:"failed"`
+	Spent      float64          `json:"spent"`
+	Skipped    int              `json:"skipped"`
+	Failures   []episodeReport  `json:"failures,omitempty"`
+	Skips      []skipReport     `json:"skips,omitempty"`
+	Transfers  []transferReport `json:"transfers,omitempty"`
+}
+
+// Downloaded episode.
+type transferReport struct {
+	Number string `json:"number"`
+	Title  string `json:"title"`
+	Host   string `json:"host"`
+	Size   int64  `json:"size"`
+	// Transfer time in seconds and speed in bytes per second.
+	Spent float64 `json:"spent"`
+	Speed float64 `json:"speed"`
+}
+
+// Transfers from one host.
+type hostReport struct {
+	Host  string  `json:"host"`
+	Files int     `json:"files"`
+	Size  int64   `json:"size"`
+	Spent float64 `json:"spent"`
+	Speed float64 `json:"speed"`
+}
+
+// Skipped episode, up to 100 of them are reported, with the reason: no-media, title, episode-type, keyword, duration, deleted, dup or quota.
+type skipReport struct {
+	Number string `json:"number"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// Failed episode.
+type episodeReport struct {
+	Number   string `json:"number"`
+	Title    string `json:"title"`
+	Filename string `json:"filename"`
+	Error    string `json:"error"`
+	// Category of the error: network, http, disk, tagging or parse.
+	Category string `json:"category,omitempty"`
+}
+
+// Write the report of the feed runs to the file.
+func writeReport(path string, runs []feedRun) error {
+	report := runReport{Time: time.Now(), Feeds: make([]feedReport, 0, len(runs))}
+	hosts := make(map[string]*hostReport)
+	for _, r := range runs {
+		fr := feedReport{Name: r.Feed.Name}
+		if r.Err != nil {
+			fr.Error, fr.Category = r.Err.Error(), errorCategory(r.Err)
+		}
+		if dl := r.DL; dl != nil {
+			fr.Downloaded, fr.Processed, fr.Failed, fr.Spent = dl.statDl, dl.statProcess, dl.statFail, dl.statTime.Seconds()
+			failures := append([]Result(nil), dl.failures...)
+			sort.SliceStable(failures, func(i, j int) bool { return lessNumber(failures[i].Number, failures[j].Number) })
+			for _, res := range failures {
+				fr.Failures = append(fr.Failures, episodeReport{
+					Number:   res.Number,
+					Title:    res.Title,
+					Filename: res.Filename,
+					Error:    res.Err.Error(),
+					Category: errorCategory(res.Err),
+				})
+			}
+			for _, res := range dl.skips {
+				fr.Skips = append(fr.Skips, skipReport{Number: res.Number, Title: res.Title, Reason: res.Reason})
+			}
+			for _, n := range dl.skipCounts {
+				fr.Skipped += n
+			}
+			for _, res := range dl.transfers {
+				fr.Transfers = append(fr.Transfers, transferReport{
+					Number: res.Number,
+					Title:  res.Title,
+					Host:   res.Host,
+					Size:   res.Size,
+					Spent:  res.Transfer.Seconds(),
+					Speed:  res.speed(),
+				})
+				h, ok := hosts[res.Host]
+				if !ok {
+					h = &hostReport{Host: res.Host}
+					hosts[res.Host] = h
+				}
+				h.Files++
+				h.Size += res.Size
+				h.Spent += res.Transfer.Seconds()
+			}
+		}
+		report.Feeds = append(report.Feeds, fr)
+	}
+	for _, h := range hosts {
+		if h.Spent > 0 {
+			h.Speed = float64(h.Size) / h.Spent
+		}
+		report.Hosts = append(report.Hosts, *h)
+	}
+	sort.Slice(report.Hosts, func(i, j int) bool { return report.Hosts[i].Speed < report.Hosts[j].Speed })
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
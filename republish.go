@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Extension of the previous file of the republished episode kept after the download.
+const BackupExt = ".bak"
+
+// Check if the enclosure or publishing date of the item differs from the ones recorded in the state DB.
+// Records without the enclosure info (made by older versions) are never taken as updated.
+func episodeUpdated(e Episode, enclosure *gofeed.Enclosure, item *gofeed.Item) bool {
+	if len(e.URL) == 0 {
+		return false
+	}
+	if e.URL != enclosure.URL {
+		return true
+	}
+	if length, err := strconv.ParseInt(enclosure.Length, 10, 64); err == nil && length > 0 && e.Length > 0 && length != e.Length {
+		return true
+	}
+	published := itemPublished(item)
+	return len(e.Published) > 0 && !published.IsZero() && published.UTC().Format(time.RFC3339) != e.Published
+}
+
+// Set the enclosure info of the item to the state DB record.
+func setEnclosureInfo(e *Episode, enclosure *gofeed.Enclosure, item *gofeed.Item) {
+	e.URL = enclosure.URL
+	e.Length, _ = strconv.ParseInt(enclosure.Length, 10, 64)
+	e.Published = ""
+	if published := itemPublished(item); !published.IsZero() {
+		e.Published = published.UTC().Format(time.RFC3339)
+	}
+}
+
+// Keep the file as a backup, so it's restored if the download fails.
+func backupFile(filename string) (restore func(), err error) {
+	if err := os.Rename(filename, filename+BackupExt); err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = os.Rename(filename+BackupExt, filename)
+	}, nil
+}
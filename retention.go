@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Mark the archived episode as played.
+func (dl *Glsdl) MarkPlayed(query string) error {
+	episode, err := dl.findEpisode(query)
+	if err != nil {
+		return err
+	}
+	key := itemKey(episode.Item)
+	e, _ := dl.state.Get(key)
+	now := time.Now()
+	e.Played, e.Position = &now, 0
+	dl.state.Put(key, e)
+	return dl.state.Save()
+}
+
+// Delete the files of the episodes played more than the configured number of days ago.
+// The records stay in the state DB marked as deleted, so the episodes aren't downloaded again.
+func (dl *Glsdl) deletePlayed() error {
+	if dl.conf.DeletePlayed <= 0 {
+		return nil
+	}
+	deadline := time.Now().AddDate(0, 0, -dl.conf.DeletePlayed)
+	for _, a := range dl.archivedItems() {
+		key := itemKey(a.Item)
+		e, _ := dl.state.Get(key)
+		if e.Played == nil || e.Played.After(deadline) {
+			continue
+		}
+		if err := os.Remove(dl.downloadDir + ps + e.Filename); err != nil {
+			return err
+		}
+		e.Deleted = true
+		dl.state.Put(key, e)
+		if !dl.porcelain {
+			_, _ = fmt.Fprintln(dl.out, "*", msg("retention.deleted", e.Filename))
+		}
+	}
+	return nil
+}
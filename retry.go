@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Delays between the attempts of the retry pass.
+const (
+	retryBaseDelay = 2 * time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// Unexpected HTTP status of the response.
+type httpStatusError struct {
+	URL    string
+	Code   int
+	Status string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.URL, e.Status)
+}
+
+// Check if the error is temporary, so the attempt may succeed later:
+// network errors, interrupted transfers, corrupt files, server errors and rate limiting.
+func retryable(err error) bool {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests || statusErr.Code == http.StatusRequestTimeout
+	}
+	return errors.Is(err, ErrNetwork) || errors.Is(err, errCorrupt)
+}
+
+// Re-attempt the items failed with retryable errors once more, one by one.
+// The delay doubles after each failure, so the host has time to recover.
+func (dl *Glsdl) retryFailed() {
+	dl.mux.Lock()
+	queue := dl.retryQueue
+	dl.retryQueue = nil
+	dl.mux.Unlock()
+
+	delay := retryBaseDelay
+	for _, item := range queue {
+		time.Sleep(delay)
+		dl.waitGroup.Add(1)
+		if res := dl.worker(item, true); res.Status == StatusFailed {
+			delay = min(delay*2, retryMaxDelay)
+		} else {
+			delay = retryBaseDelay
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Generic names of the download endpoints, meaningless as the episode names.
+var genericNames = map[string]bool{
+	"download": true, "media": true, "file": true, "stream": true, "audio": true, "episode": true,
+	"play": true, "listen": true, "redirect": true, "index": true, "default": true,
+}
+
+// Runs of characters unsafe in the filenames and the separators of the words.
+var unsafeNameChars = regexp.MustCompile(`[\x00-\x1f<>:"/\\|?*_]+|\s{2,}`)
+
+// Get the filename of the response: Content-Disposition one or the last element of the URL path
+// after the redirects.
+func responseName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && len(params["filename"]) > 0 {
+		return path.Base(strings.ReplaceAll(params["filename"], `\`, "/"))
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		return path.Base(resp.Request.URL.Path)
+	}
+	return ""
+}
+
+// Clean the name provided by the server to use it as the episode title: the extension is stripped
+// and the unsafe characters are replaced. Empty if the name is meaningless.
+func cleanServerName(name string) string {
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = strings.TrimSpace(unsafeNameChars.ReplaceAllString(name, " "))
+	if len(name) == 0 || name == "." || genericNames[strings.ToLower(name)] {
+		return ""
+	}
+	return name
+}
+
+// Get the name of the item file provided by the server, asked by HEAD request once per enclosure URL.
+// The last element of the enclosure URL is used if the server doesn't respond.
+func (dl *Glsdl) serverName(item *gofeed.Item) string {
+	m, ok := dl.itemMedia(item)
+	if !ok || m.Type == youtubeType || isTorrent(m.Enclosure) {
+		return ""
+	}
+	dl.mux.Lock()
+	name, ok := dl.serverNames[m.URL]
+	dl.mux.Unlock()
+	if ok {
+		return name
+	}
+	if info, err := headFile(m.URL); err == nil {
+		name = cleanServerName(info.Name)
+	}
+	if u, err := url.Parse(m.URL); err == nil && len(name) == 0 {
+		name = cleanServerName(path.Base(u.Path))
+	}
+	dl.mux.Lock()
+	if dl.serverNames == nil {
+		dl.serverNames = make(map[string]string)
+	}
+	dl.serverNames[m.URL] = name
+	dl.mux.Unlock()
+	return name
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+)
+
+// Name of the service registered by service install.
+const serviceName = "glsdl"
+
+// Daemon flags passed to the service when set in the command line.
+var serviceFlags = []string{"interval", "http", "socket", "t", "tag-threads", "adaptive", "polite", "parallel", "lang"}
+
+// Get the path of the running executable and the flags of the service: the current config
+// and the daemon flags set in the command line.
+func serviceCommand() (exe string, args []string, err error) {
+	if exe, err = os.Executable(); err != nil {
+		return
+	}
+	if exe, err = filepath.Abs(exe); err != nil {
+		return
+	}
+	conf, err := filepath.Abs(*confPath)
+	if err != nil {
+		return
+	}
+	args = []string{"-config", conf}
+	for _, name := range serviceFlags {
+		if flagSet(name) {
+			// Boolean flags take the value only after "=".
+			args = append(args, "-"+name+"="+flag.Lookup(name).Value.String())
+		}
+	}
+	return
+}
@@ -0,0 +1,100 @@
+//go:build darwin
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Label of the launchd agent.
+const launchdLabel = "com.github.koykov.glsdl"
+
+// Get the path of the launchd agent plist.
+func launchdPlist() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// Register the daemon as launchd agent of the user, started at login and restarted on failure.
+// The output goes to ~/Library/Logs/glsdl.log.
+func installService() error {
+	exe, args, err := serviceCommand()
+	if err != nil {
+		return err
+	}
+	path, err := launchdPlist()
+	if err != nil {
+		return err
+	}
+	logFile := filepath.Join(filepath.Dir(filepath.Dir(path)), "Logs", serviceName+".log")
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+  <key>Label</key>
+  <string>` + launchdLabel + `</string>
+  <key>ProgramArguments</key>
+  <array>
+`)
+	for _, arg := range append(append([]string{exe}, args...), "daemon") {
+		buf.WriteString("    <string>")
+		_ = xml.EscapeText(&buf, []byte(arg))
+		buf.WriteString("</string>\n")
+	}
+	buf.WriteString(`  </array>
+  <key>RunAtLoad</key>
+  <true/>
+  <key>KeepAlive</key>
+  <true/>
+  <key>StandardOutPath</key>
+  <string>`)
+	_ = xml.EscapeText(&buf, []byte(logFile))
+	buf.WriteString(`</string>
+  <key>StandardErrorPath</key>
+  <string>`)
+	_ = xml.EscapeText(&buf, []byte(logFile))
+	buf.WriteString(`</string>
+</dict>
+</plist>
+`)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return launchctl("load", "-w", path)
+}
+
+// Stop and remove the launchd agent.
+func uninstallService() error {
+	path, err := launchdPlist()
+	if err != nil {
+		return err
+	}
+	if err := launchctl("unload", "-w", path); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Run the daemon, launchd runs it like any other process.
+func runService(daemon func() error) error {
+	return daemon()
+}
+
+// Run launchctl with the arguments.
+func launchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,87 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Get the path of the systemd user unit.
+func systemdUnit() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", serviceName+".service"), nil
+}
+
+// Register the daemon as systemd user service, started at login and restarted on failure.
+func installService() error {
+	exe, args, err := serviceCommand()
+	if err != nil {
+		return err
+	}
+	path, err := systemdUnit()
+	if err != nil {
+		return err
+	}
+	command := make([]string, 0, len(args)+2)
+	for _, arg := range append(append([]string{exe}, args...), "daemon") {
+		command = append(command, strconv.Quote(arg))
+	}
+	unit := `[Unit]
+Description=GolangShow Downloader
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=` + strings.Join(command, " ") + `
+ExecReload=/bin/kill -HUP $MAINPID
+WatchdogSec=60
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return err
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", serviceName)
+}
+
+// Stop and remove the systemd user service.
+func uninstallService() error {
+	path, err := systemdUnit()
+	if err != nil {
+		return err
+	}
+	if err := systemctl("disable", "--now", serviceName); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+// Run the daemon, systemd runs it like any other process.
+func runService(daemon func() error) error {
+	return daemon()
+}
+
+// Run systemctl for the user services.
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	return cmd.Run()
+}
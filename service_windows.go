@@ -0,0 +1,115 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// Register the daemon as Windows service started automatically, running service run command.
+func installService() error {
+	exe, args, err := serviceCommand()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = m.Disconnect()
+	}()
+	if s, err := m.OpenService(serviceName); err == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "GolangShow Downloader",
+		Description: "Downloads new podcast episodes and tags them.",
+		StartType:   mgr.StartAutomatic,
+	}, append(args, "service", "run")...)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+	return s.Start()
+}
+
+// Stop and remove the Windows service.
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = m.Disconnect()
+	}()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = s.Close()
+	}()
+	// The service may be stopped already.
+	_, _ = s.Control(svc.Stop)
+	return s.Delete()
+}
+
+// Run the daemon under the service control manager, the log is written to glsdl.log next to the config.
+// Started from the console it runs like the daemon command.
+func runService(daemon func() error) error {
+	service, err := svc.IsWindowsService()
+	if err != nil || !service {
+		return daemon()
+	}
+	logFile, err := os.OpenFile(filepath.Dir(*confPath)+ps+serviceName+".log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		log.SetOutput(logFile)
+		defer func() {
+			_ = logFile.Close()
+		}()
+	}
+	return svc.Run(serviceName, serviceHandler{daemon: daemon})
+}
+
+// Handler of the service control requests.
+type serviceHandler struct {
+	daemon func() error
+}
+
+func (h serviceHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() {
+		done <- h.daemon()
+	}()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				log.Println(err)
+				return true, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				changes <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32((30 * time.Second).Milliseconds())}
+				daemonSignals <- os.Interrupt
+			}
+		}
+	}
+}
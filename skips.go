@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Maximum number of the skipped episodes kept for the report of the feed.
+const maxSkips = 100
+
+// Record the skipped episode, only the first maxSkips ones are kept, the reasons are counted for all of them.
+func (dl *Glsdl) recordSkip(res Result) {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+	if dl.skipCounts == nil {
+		dl.skipCounts = make(map[string]int)
+	}
+	dl.skipCounts[res.Reason]++
+	if len(dl.skips) < maxSkips {
+		dl.skips = append(dl.skips, res)
+	}
+}
+
+// Get the numbers of the skipped episodes by the reasons, the most frequent first.
+func (dl *Glsdl) skipReasons() []string {
+	dl.mux.Lock()
+	defer dl.mux.Unlock()
+	reasons := make([]string, 0, len(dl.skipCounts))
+	for reason := range dl.skipCounts {
+		reasons = append(reasons, reason)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if a, b := dl.skipCounts[reasons[i]], dl.skipCounts[reasons[j]]; a != b {
+			return a > b
+		}
+		return reasons[i] < reasons[j]
+	})
+	lines := make([]string, 0, len(reasons))
+	for _, reason := range reasons {
+		lines = append(lines, msg("skip."+reason)+": "+strconv.Itoa(dl.skipCounts[reason]))
+	}
+	return lines
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Source of the feed items processed by the download and tagging pipeline. Besides RSS and Atom
+// feeds it may be a directory of the feed snapshots or an API client returning the items as a feed.
+// The feed is returned rather than the bare items on purpose: the pipeline reads the channel too
+// (author, image, categories, funding and license for the tags, numbering and sidecar metadata),
+// and the item fields it relies on (enclosures, iTunes and Podcasting 2.0 extensions) are the gofeed
+// ones, so the other sources fill only the fields they have.
+type Source interface {
+	// Get the feed with its items.
+	Fetch(ctx context.Context) (*gofeed.Feed, error)
+}
+
+// Feed of http(s) URL, YouTube channel or playlist, local file or stdin.
+type urlSource string
+
+func (s urlSource) Fetch(ctx context.Context) (*gofeed.Feed, error) {
+	url, err := s.location()
+	if err != nil {
+		return nil, err
+	}
+	r, err := openSource(ctx, url)
+	if err != nil {
+		return nil, classify(err, ErrNetwork)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+	return gofeed.NewParser().Parse(r)
+}
+
+// Get the location of the feed, YouTube channels and playlists have RSS feeds.
+func (s urlSource) location() (string, error) {
+	if !isYouTubeURL(string(s)) {
+		return string(s), nil
+	}
+	url, err := youtubeFeedURL(string(s))
+	return url, classify(err, ErrNetwork)
+}
+
+// Name of the copy of the feed kept in the download directory for the conditional requests.
+const FeedCacheFile = ".glsdl.feed.json"
+
+// Copy of the feed with its validators.
+type feedCache struct {
+	URL      string `json:"url"`
+	ETag     string `json:"etag,omitempty"`
+	Modified string `json:"modified,omitempty"`
+	Data     []byte `json:"data"`
+}
+
+// Feed of http(s) URL requested conditionally, see -polite flag: the copy of the feed is used
+// if the server says it isn't modified since the previous request.
+type cachedSource struct {
+	url  urlSource
+	path string
+}
+
+func (s cachedSource) Fetch(ctx context.Context) (*gofeed.Feed, error) {
+	url, err := s.url.location()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return s.url.Fetch(ctx)
+	}
+	var cache feedCache
+	if data, err := os.ReadFile(s.path); err != nil || json.Unmarshal(data, &cache) != nil || cache.URL != url {
+		cache = feedCache{}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(cache.Data) > 0 {
+		if len(cache.ETag) > 0 {
+			req.Header.Set("If-None-Match", cache.ETag)
+		}
+		if len(cache.Modified) > 0 {
+			req.Header.Set("If-Modified-Since", cache.Modified)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, classify(err, ErrNetwork)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && len(cache.Data) > 0:
+		return dataSource(cache.Data).Fetch(ctx)
+	case resp.StatusCode != http.StatusOK:
+		return nil, &httpStatusError{URL: url, Code: resp.StatusCode, Status: resp.Status}
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, classify(err, ErrNetwork)
+	}
+	feed, err := dataSource(data).Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cache = feedCache{URL: url, ETag: resp.Header.Get("ETag"), Modified: resp.Header.Get("Last-Modified"), Data: data}
+	if len(cache.ETag) > 0 || len(cache.Modified) > 0 {
+		if err := cache.save(s.path); err != nil {
+			log.Println(err)
+		}
+	}
+	return feed, nil
+}
+
+// Write the copy of the feed, the read-only archive isn't changed.
+func (c feedCache) save(path string) error {
+	if *readOnly {
+		return nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Feed read before, like -feed source read once.
+type dataSource []byte
+
+func (s dataSource) Fetch(context.Context) (*gofeed.Feed, error) {
+	return gofeed.NewParser().Parse(bytes.NewReader(s))
+}
+
+// Directory of the feed snapshots, like the copies saved by cron or a web archive.
+// The snapshots are parsed in parallel and merged: the newest one gives the feed info and the copy
+// of the item kept in several snapshots, the items are sorted from the newest.
+type dirSource string
+
+func (s dirSource) Fetch(ctx context.Context) (*gofeed.Feed, error) {
+	entries, err := os.ReadDir(string(s))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".xml", ".rss", ".atom", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(string(s), entry.Name()))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no feed snapshots", s)
+	}
+
+	feeds := make([]*gofeed.Feed, len(files))
+	errs := make([]error, len(files))
+	slots := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() {
+				<-slots
+			}()
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			data, err := os.ReadFile(file)
+			if err == nil {
+				feeds[i], err = gofeed.NewParser().Parse(bytes.NewReader(data))
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", file, err)
+			}
+		}(i, file)
+	}
+	wg.Wait()
+
+	snapshots := make([]*gofeed.Feed, 0, len(feeds))
+	for i, feed := range feeds {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if feed != nil {
+			snapshots = append(snapshots, feed)
+		}
+	}
+	return mergeSnapshots(snapshots), nil
+}
+
+// Merge the snapshots of the feed, the newest by the update time first.
+func mergeSnapshots(snapshots []*gofeed.Feed) *gofeed.Feed {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return feedUpdated(snapshots[i]).After(feedUpdated(snapshots[j]))
+	})
+	result := *snapshots[0]
+	result.Items = make([]*gofeed.Item, 0, len(snapshots[0].Items))
+	seen := make(map[string]bool)
+	for _, feed := range snapshots {
+		for _, item := range feed.Items {
+			if key := itemKey(item); !seen[key] {
+				seen[key] = true
+				result.Items = append(result.Items, item)
+			}
+		}
+	}
+	sort.SliceStable(result.Items, func(i, j int) bool {
+		return itemPublished(result.Items[i]).After(itemPublished(result.Items[j]))
+	})
+	return &result
+}
+
+// Get the update time of the feed snapshot, the publishing date of the newest item if not set.
+func feedUpdated(feed *gofeed.Feed) time.Time {
+	if feed.UpdatedParsed != nil {
+		return *feed.UpdatedParsed
+	}
+	if feed.PublishedParsed != nil {
+		return *feed.PublishedParsed
+	}
+	var updated time.Time
+	for _, item := range feed.Items {
+		if published := itemPublished(item); published.After(updated) {
+			updated = published
+		}
+	}
+	return updated
+}
+
+// Get the source of the configured feed: -feed source, directory of the snapshots or the feed URL,
+// requested conditionally in -polite mode.
+func feedSource(feed *FeedConfig) Source {
+	if feed.source != nil {
+		return feed.source
+	}
+	path := expandHome(strings.TrimPrefix(feed.URL, "file://"))
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return dirSource(path)
+	}
+	if *polite {
+		return cachedSource{url: urlSource(feed.URL), path: feed.dir + ps + FeedCacheFile}
+	}
+	return urlSource(feed.URL)
+}
+
+// Open the feed source: http(s) URL, local file path, file:// URL or "-" for stdin.
+func openSource(ctx context.Context, source string) (io.ReadCloser, error) {
+	switch {
+	case source == "-":
+		return io.NopCloser(os.Stdin), nil
+	case strings.HasPrefix(source, "file://"):
+		return os.Open(strings.TrimPrefix(source, "file://"))
+	case strings.Contains(source, "://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return nil, &httpStatusError{URL: source, Code: resp.StatusCode, Status: resp.Status}
+		}
+		return resp.Body, nil
+	}
+	return os.Open(source)
+}
+
+// Check if the value is a feed source rather than a feed name.
+func isSource(value string) bool {
+	if value == "-" || strings.Contains(value, "://") {
+		return true
+	}
+	_, err := os.Stat(value)
+	return err == nil
+}
+
+// Get the feed settings of the source given by -feed flag.
+// The source is read once, so stdin may be used. The config feed with the same URL or title is used
+// if any, so the cached copy of the feed goes to its download directory.
+func (c *Config) sourceFeed(source string) (*FeedConfig, error) {
+	var src Source
+	if info, err := os.Stat(source); err == nil && info.IsDir() {
+		src = dirSource(source)
+	} else {
+		r, err := openSource(context.Background(), source)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+		src = dataSource(data)
+	}
+	feed, err := src.Fetch(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+
+	for _, fc := range c.Feeds {
+		if fc.URL == source || (len(feed.FeedLink) > 0 && fc.URL == feed.FeedLink) || fc.Name == sanitizeName(feed.Title) {
+			result := *fc
+			result.source = src
+			return &result, nil
+		}
+	}
+	if len(feed.Title) == 0 {
+		return nil, fmt.Errorf("%s: feed has no title, add it to the config", source)
+	}
+	tmp := Config{Dir: c.Dir, Feeds: []*FeedConfig{{Name: sanitizeName(feed.Title), URL: source}}}
+	if err := tmp.init(); err != nil {
+		return nil, err
+	}
+	tmp.Feeds[0].source = src
+	return tmp.Feeds[0], nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Get the path the episode is downloaded to before moving it to the library, see -temp-dir flag.
+// Without the temp directory the episode is downloaded in place.
+func (dl *Glsdl) stagingFile(dest string) string {
+	if len(dl.tempDir) == 0 {
+		return dest
+	}
+	return dl.tempDir + ps + sanitizeName(dl.conf.Name) + " - " + filepath.Base(dest)
+}
+
+// Move the downloaded episode from the temp directory to the library.
+// Another file system gets a copy under temporary name first, so the incomplete file isn't seen there.
+func unstage(staged, dest string) error {
+	if staged == dest {
+		return nil
+	}
+	if err := os.Rename(staged, dest); err == nil {
+		return nil
+	}
+	defer func() {
+		_ = os.Remove(staged)
+	}()
+	tmp := dest + ".part"
+	if err := copyFile(staged, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := durable(tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
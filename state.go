@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Name of the state DB file kept in the download directory.
+const StateFile = ".glsdl.json"
+
+// Persistent state of the download directory: maps feed items to the local files.
+type State struct {
+	path     string
+	mux      sync.Mutex
+	Episodes map[string]*Episode `json:"episodes"`
+	// Downloaded bytes by month, like 2006-01.
+	Traffic map[string]int64 `json:"traffic,omitempty"`
+}
+
+// State DB record of one episode.
+type Episode struct {
+	GUID  string `json:"guid"`
+	Title string `json:"title"`
+	// Filename relative to the download directory.
+	Filename string    `json:"filename"`
+	Updated  time.Time `json:"updated"`
+	// Size and Last-Modified header of the remote file at the download time.
+	Size     int64  `json:"size,omitempty"`
+	Modified string `json:"modified,omitempty"`
+	// Enclosure URL, length and publishing date of the item at the download time.
+	URL       string `json:"url,omitempty"`
+	Length    int64  `json:"length,omitempty"`
+	Published string `json:"published,omitempty"`
+	// The enclosure was changed by the publisher, but the episode wasn't downloaded again.
+	Republished bool `json:"republished,omitempty"`
+	// SHA-256 checksum of the downloaded file before tagging.
+	Checksum string `json:"checksum,omitempty"`
+	// The downloaded file matched podcast:integrity hash before tagging.
+	Verified bool `json:"verified,omitempty"`
+	// Path of the corrupt file moved to the quarantine directory, the episode is downloaded again.
+	Quarantined string `json:"quarantined,omitempty"`
+	// Path of the identical file of another feed kept instead of the copy, see -dedup flag.
+	Duplicate string `json:"duplicate,omitempty"`
+	// Error of the last failed attempt and the number of failed attempts in a row.
+	Error    string `json:"error,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+	// Playback position in seconds.
+	Position float64 `json:"position,omitempty"`
+	// Time when the episode was played to the end.
+	Played *time.Time `json:"played,omitempty"`
+	// The file was deleted by retention and shouldn't be downloaded again.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// Load the state DB from the file. Missing file means empty state.
+func LoadState(path string) (*State, error) {
+	s := State{
+		path:     path,
+		Episodes: make(map[string]*Episode),
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &s, nil
+	}
+	if err != nil {
+		return &s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &s, err
+	}
+	if s.Episodes == nil {
+		s.Episodes = make(map[string]*Episode)
+	}
+	return &s, nil
+}
+
+// Get a copy of the episode record by its key.
+func (s *State) Get(key string) (Episode, bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if e, ok := s.Episodes[key]; ok {
+		return *e, true
+	}
+	return Episode{}, false
+}
+
+// Store the episode record.
+func (s *State) Put(key string, e Episode) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	e.Updated = time.Now()
+	s.Episodes[key] = &e
+}
+
+// Check if the filename is recorded by any episode.
+func (s *State) HasFilename(filename string) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, e := range s.Episodes {
+		if e.Filename == filename {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the filenames of the played episodes, including the deleted ones.
+func (s *State) PlayedFiles() (names []string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for _, e := range s.Episodes {
+		if e.Played != nil && len(e.Filename) > 0 {
+			names = append(names, e.Filename)
+		}
+	}
+	return
+}
+
+// Write the state DB to the file.
+// The data is written to the temporary file first to avoid corrupted state on failure.
+func (s *State) Save() error {
+	s.mux.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mux.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	return durable(s.path)
+}
+
+// Apply the change to the episode record of the state DB on disk under the lock of the download
+// directory, so the records written by the runs finished meanwhile are kept.
+func (dl *Glsdl) updateEpisode(key string, update func(e *Episode)) error {
+	unlock, err := dl.waitLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	state, err := LoadState(dl.state.path)
+	if err != nil {
+		return err
+	}
+	e, _ := state.Get(key)
+	update(&e)
+	state.Put(key, e)
+	if err := state.Save(); err != nil {
+		return err
+	}
+	dl.state = state
+	return nil
+}
+
+// Get the state DB key of the feed item.
+func itemKey(item *gofeed.Item) string {
+	if len(item.GUID) > 0 {
+		return item.GUID
+	}
+	if e, ok := itemEnclosure(item); ok {
+		return e.URL
+	}
+	return item.Link
+}
+
+// Record the successfully processed item, the remote info is updated if the file was downloaded.
+// The enclosure info is kept until the download, so the republished episode stays flagged.
+func (dl *Glsdl) recordDownload(key string, item *gofeed.Item, enclosure *gofeed.Enclosure, title, filename string, downloaded bool, remote remoteInfo) {
+	e, _ := dl.state.Get(key)
+	e.GUID, e.Title, e.Filename = item.GUID, title, filename
+	e.Error, e.Attempts = "", 0
+	if downloaded {
+		e.Size, e.Modified = remote.Size, remote.Modified
+		e.Checksum, e.Duplicate, e.Verified = remote.Checksum, "", remote.Verified
+		e.Quarantined = ""
+	}
+	switch {
+	case downloaded || len(e.URL) == 0:
+		setEnclosureInfo(&e, enclosure, item)
+		e.Republished = false
+	case episodeUpdated(e, enclosure, item):
+		e.Republished = true
+	}
+	dl.state.Put(key, e)
+}
+
+// Record the failed attempt to process the item.
+func (dl *Glsdl) recordFailure(key string, item *gofeed.Item, err error) {
+	e, _ := dl.state.Get(key)
+	if len(e.GUID) == 0 {
+		e.GUID = item.GUID
+	}
+	if len(e.Title) == 0 {
+		e.Title = item.Title
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.Attempts++
+	dl.state.Put(key, e)
+}
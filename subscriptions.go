@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Load the config file as is, without the overrides of the flags and env vars, to edit it.
+// Missing file means default config.
+func loadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return DefaultConfig(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	conf := &Config{}
+	if err := json.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return conf, nil
+}
+
+// Validate the config and write it to the file.
+// The data is written to the temporary file first to avoid corrupted config on failure.
+func (c *Config) save(path string) error {
+	if err := c.init(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return durable(path)
+}
+
+// Subscribe to the feed. The feed is downloaded and parsed before adding it to the config,
+// its title is the name unless the name is given.
+func AddFeed(path, url, name string, out io.Writer) error {
+	if len(url) == 0 {
+		return fmt.Errorf("%s", msg("subs.nourl"))
+	}
+	conf, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	for _, feed := range conf.Feeds {
+		if feed.URL == url {
+			return fmt.Errorf("%s", msg("subs.subscribed", url, feed.Name))
+		}
+	}
+	feed, err := fetchFeed(url)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, msg("init.found", feed.Title, len(feed.Items)))
+	if len(name) == 0 {
+		name = sanitizeName(strings.TrimSpace(feed.Title))
+	}
+	if _, ok := conf.feedByName(name); ok {
+		return fmt.Errorf("%s", msg("subs.exists", name))
+	}
+	conf.Feeds = append(conf.Feeds, &FeedConfig{Name: name, URL: url})
+	if err := conf.save(path); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, msg("subs.added", name))
+	return nil
+}
+
+// Unsubscribe from the feed. The downloaded episodes and the state DB are kept.
+func RemoveFeed(path, name string, out io.Writer) error {
+	conf, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	feed, ok := conf.feedByName(name)
+	if !ok {
+		return fmt.Errorf("feed %s not found", name)
+	}
+	if err := conf.init(); err != nil {
+		return err
+	}
+	for i, f := range conf.Feeds {
+		if f == feed {
+			conf.Feeds = append(conf.Feeds[:i], conf.Feeds[i+1:]...)
+			break
+		}
+	}
+	if err := conf.save(path); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, msg("subs.removed", name, feed.dir))
+	return nil
+}
+
+// Rename the feed. The default download directory with the state DB is renamed too,
+// the tokens and notification topics of the feed are kept.
+func RenameFeed(path, name, newName string, out io.Writer) error {
+	if len(newName) == 0 {
+		return fmt.Errorf("%s", msg("subs.noname"))
+	}
+	conf, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	feed, ok := conf.feedByName(name)
+	if !ok {
+		return fmt.Errorf("feed %s not found", name)
+	}
+	if _, ok := conf.feedByName(newName); ok {
+		return fmt.Errorf("%s", msg("subs.exists", newName))
+	}
+	if err := conf.init(); err != nil {
+		return err
+	}
+	oldDir := feed.dir
+	feed.Name = newName
+	if err := conf.init(); err != nil {
+		return err
+	}
+	if len(feed.Dir) == 0 && oldDir != feed.dir {
+		if _, err := os.Stat(feed.dir); err == nil {
+			return fmt.Errorf("%s", msg("subs.direxists", feed.dir))
+		}
+		if err := os.Rename(oldDir, feed.dir); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	for _, n := range conf.Notify {
+		if topic, ok := n.Topics[name]; ok {
+			delete(n.Topics, name)
+			n.Topics[newName] = topic
+		}
+	}
+	auth, err := loadAuthStore(filepath.Dir(path) + ps + TokensFile)
+	if err != nil {
+		return err
+	}
+	if tok, ok := auth.Tokens[name]; ok {
+		delete(auth.Tokens, name)
+		auth.Tokens[newName] = tok
+		if err := auth.Save(); err != nil {
+			return err
+		}
+	}
+	if err := conf.save(path); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(out, msg("subs.renamed", name, newName))
+	return nil
+}
+
+// Enable or disable the feed. Disabled feed stays in the config, but isn't processed unless
+// it's selected by -feed flag.
+func EnableFeed(path, name string, enabled bool, out io.Writer) error {
+	conf, err := loadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	feed, ok := conf.feedByName(name)
+	if !ok {
+		return fmt.Errorf("feed %s not found", name)
+	}
+	feed.Disabled = !enabled
+	if err := conf.save(path); err != nil {
+		return err
+	}
+	if enabled {
+		_, _ = fmt.Fprintln(out, msg("subs.enabled", name))
+	} else {
+		_, _ = fmt.Fprintln(out, msg("subs.disabled", name))
+	}
+	return nil
+}
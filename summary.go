@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Print the table of the processed episodes sorted by number.
+func (dl *Glsdl) printSummary() {
+	dl.outMux.Lock()
+	results := dl.results
+	dl.results = nil
+	dl.outMux.Unlock()
+	if len(results) == 0 {
+		return
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return lessNumber(results[i].Number, results[j].Number)
+	})
+
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, msg("summary.header"))
+	for _, res := range results {
+		size, spent, speed, errText := "", "", "", ""
+		if res.Size > 0 {
+			size = formatSize(res.Size)
+		}
+		if res.Transfer > 0 {
+			speed = formatSize(int64(res.speed())) + "/s"
+		}
+		if res.Status != StatusSkipped {
+			spent = res.Duration.Round(time.Millisecond).String()
+		}
+		if res.Err != nil {
+			errText = res.Err.Error()
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", res.Number, res.Title, res.Status, size, spent, speed, errText)
+	}
+	_ = w.Flush()
+	dl.println(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// Compare the episode numbers: numerically if both are numbers, otherwise as strings.
+func lessNumber(a, b string) bool {
+	na, erra := strconv.ParseFloat(a, 64)
+	nb, errb := strconv.ParseFloat(b, 64)
+	switch {
+	case erra == nil && errb == nil:
+		return na < nb
+	case erra == nil:
+		return true
+	case errb == nil:
+		return false
+	}
+	return a < b
+}
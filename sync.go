@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Sync options of the device.
+type SyncOptions struct {
+	// Mount point of the device.
+	Target string
+	// Bitrate to transcode the episodes to with ffmpeg, like "64k". Empty means copy as is.
+	Bitrate string
+	// Maximum total size of the files on the device in bytes, 0 means unlimited.
+	Quota int64
+}
+
+// Copy unplayed episodes to the device, newest first, and remove played ones from it.
+// Episodes are placed to <target>/<feed name>/.
+func (dl *Glsdl) Sync(opts SyncOptions, out io.Writer) error {
+	if _, err := dl.parseFeed(); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(opts.Target); err != nil || !fi.IsDir() {
+		return fmt.Errorf("sync target %s is not a directory", opts.Target)
+	}
+	dir := filepath.Join(opts.Target, sanitizeName(dl.conf.Name))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Free the space first. Played episodes may be already deleted from the download directory.
+	for _, name := range dl.state.PlayedFiles() {
+		dest := filepath.Join(dir, syncName(name, opts.Bitrate))
+		if _, err := os.Stat(dest); err == nil {
+			if err := os.Remove(dest); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(out, "*", msg("sync.removed", name))
+		}
+	}
+
+	used, err := dirSize(opts.Target)
+	if err != nil {
+		return err
+	}
+	archived := dl.archivedItems()
+	for i := len(archived) - 1; i >= 0; i-- {
+		e, _ := dl.state.Get(itemKey(archived[i].Item))
+		dest := filepath.Join(dir, syncName(e.Filename, opts.Bitrate))
+		if e.Played != nil {
+			continue
+		}
+		if _, err := os.Stat(dest); err == nil {
+			continue
+		}
+		src := dl.downloadDir + ps + e.Filename
+		if len(opts.Bitrate) == 0 && opts.Quota > 0 {
+			// Check the space before copying.
+			if fi, err := os.Stat(src); err == nil && used+fi.Size() > opts.Quota {
+				_, _ = fmt.Fprintln(out, "*", msg("sync.quota", e.Filename))
+				continue
+			}
+		}
+		if err := syncFile(src, dest, opts.Bitrate); err != nil {
+			return err
+		}
+		fi, err := os.Stat(dest)
+		if err != nil {
+			return err
+		}
+		if opts.Quota > 0 && used+fi.Size() > opts.Quota {
+			// Transcoded file size is known after the transcoding only.
+			if err := os.Remove(dest); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintln(out, "*", msg("sync.quota", e.Filename))
+			continue
+		}
+		used += fi.Size()
+		_, _ = fmt.Fprintln(out, "*", msg("sync.copied", e.Filename))
+	}
+	return nil
+}
+
+// Get the name of the file on the device, transcoded files are MP3.
+func syncName(filename, bitrate string) string {
+	if len(bitrate) == 0 {
+		return filename
+	}
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".mp3"
+}
+
+// Copy or transcode the file to the device.
+// Temporary file is used to not leave partial files on the device.
+func syncFile(src, dest, bitrate string) error {
+	tmp := dest + ".part"
+	var err error
+	if len(bitrate) == 0 {
+		err = linkFile(src, tmp)
+	} else {
+		cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error", "-i", src,
+			"-map_metadata", "0", "-codec:a", "libmp3lame", "-b:a", bitrate, "-f", "mp3", tmp)
+		cmd.Stderr = os.Stderr
+		err = cmd.Run()
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return err
+	}
+	return durable(dest)
+}
+
+// Get the total size of the files in the directory.
+func dirSize(dir string) (size int64, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += fi.Size()
+		}
+		return nil
+	})
+	return
+}
+
+// Parse the size like "512M" or "2G", plain number means bytes.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) == 0 {
+		return 0, nil
+	}
+	mult := int64(1)
+	switch s[len(s)-1] {
+	case 'K':
+		mult = 1 << 10
+	case 'M':
+		mult = 1 << 20
+	case 'G':
+		mult = 1 << 30
+	case 'T':
+		mult = 1 << 40
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
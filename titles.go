@@ -0,0 +1,82 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Matches the trailing episode counter of GUIDs like "https://example.com/episode/42/" or "show-ep-042".
+var guidCounter = regexp.MustCompile(`(?:^|[/#=_-])(?:ep|episode)?-?(\d{1,5})/?$`)
+
+// Title parser extracts the episode number and title from the feed item.
+type TitleParser interface {
+	// Returns false if the parser can't handle the item.
+	ParseTitle(item *gofeed.Item) (number, title string, ok bool)
+}
+
+// Chain of title parsers tried in order until the first success.
+type TitleParserChain []TitleParser
+
+func (c TitleParserChain) ParseTitle(item *gofeed.Item) (number, title string, ok bool) {
+	for _, p := range c {
+		if number, title, ok = p.ParseTitle(item); ok {
+			return
+		}
+	}
+	return "", "", false
+}
+
+// Title parser by the list of regular expressions.
+// Named capture groups "number" and "title" extract the episode number and title.
+type RegexTitleParser struct {
+	Patterns []*regexp.Regexp
+}
+
+func (p RegexTitleParser) ParseTitle(item *gofeed.Item) (number, title string, ok bool) {
+	for _, re := range p.Patterns {
+		res := re.FindStringSubmatch(item.Title)
+		if len(res) == 0 {
+			continue
+		}
+		title = item.Title
+		if i := re.SubexpIndex("number"); i >= 0 {
+			number = res[i]
+		}
+		if i := re.SubexpIndex("title"); i >= 0 {
+			title = res[i]
+		}
+		return number, title, true
+	}
+	return "", "", false
+}
+
+// Title parser by the itunes:episode tag.
+type ITunesTitleParser struct{}
+
+func (p ITunesTitleParser) ParseTitle(item *gofeed.Item) (number, title string, ok bool) {
+	if item.ITunesExt == nil || len(item.ITunesExt.Episode) == 0 {
+		return "", "", false
+	}
+	return item.ITunesExt.Episode, item.Title, true
+}
+
+// Title parser by the counter at the end of GUID.
+type GUIDTitleParser struct{}
+
+func (p GUIDTitleParser) ParseTitle(item *gofeed.Item) (number, title string, ok bool) {
+	res := guidCounter.FindStringSubmatch(item.GUID)
+	if len(res) == 0 {
+		return "", "", false
+	}
+	return res[1], item.Title, true
+}
+
+// Get the default chain of title parsers for the patterns.
+func defaultTitleParser(patterns []*regexp.Regexp) TitleParser {
+	return TitleParserChain{
+		RegexTitleParser{Patterns: patterns},
+		ITunesTitleParser{},
+		GUIDTitleParser{},
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLS settings of the HTTP requests.
+type TLSOptions struct {
+	// PEM file with the CA certificates trusted in addition to the system ones.
+	CA string
+	// PEM files of the client certificate and its key.
+	Cert, Key string
+	// Minimum TLS version: 1.0, 1.1, 1.2 or 1.3.
+	MinVersion string
+	// Skip the verification of the server certificates.
+	Insecure bool
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build TLS config of the options, nil means the defaults.
+func (o TLSOptions) config() (*tls.Config, error) {
+	if o == (TLSOptions{}) {
+		return nil, nil
+	}
+	conf := &tls.Config{InsecureSkipVerify: o.Insecure}
+	if len(o.MinVersion) > 0 {
+		v, ok := tlsVersions[o.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS version %s", o.MinVersion)
+		}
+		conf.MinVersion = v
+	}
+	if len(o.CA) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		data, err := os.ReadFile(o.CA)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s: no certificates found", o.CA)
+		}
+		conf.RootCAs = pool
+	}
+	if len(o.Cert) > 0 || len(o.Key) > 0 {
+		if len(o.Cert) == 0 || len(o.Key) == 0 {
+			return nil, errors.New("both client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(o.Cert, o.Key)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// Apply the options to the default transport used by all requests.
+func (o TLSOptions) apply() error {
+	conf, err := o.config()
+	if err != nil || conf == nil {
+		return err
+	}
+	http.DefaultTransport.(*http.Transport).TLSClientConfig = conf
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// BitTorrent client downloading torrent enclosures.
+type torrentClient interface {
+	// Download the largest file of the torrent (.torrent URL or magnet link) to the path.
+	Download(source, dest string) (int64, error)
+	// Wait for the seeding to finish and stop the client.
+	Close()
+}
+
+// Settings of the torrent client.
+type TorrentOptions struct {
+	// Seed the downloaded files until the uploaded data reaches the ratio of the file size, 0 disables seeding.
+	SeedRatio float64
+	// Maximum time of the seeding after the run.
+	SeedTime time.Duration
+}
+
+// Check if the enclosure is a torrent.
+func isTorrent(e *gofeed.Enclosure) bool {
+	if strings.EqualFold(e.Type, "application/x-bittorrent") || strings.HasPrefix(e.URL, "magnet:") {
+		return true
+	}
+	u := e.URL
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	return strings.EqualFold(path.Ext(u), ".torrent")
+}
+
+// Shared torrent client created on the first torrent enclosure.
+type torrentBackend struct {
+	opts   TorrentOptions
+	once   sync.Once
+	client torrentClient
+	err    error
+}
+
+func (b *torrentBackend) get() (torrentClient, error) {
+	if b == nil {
+		return nil, errors.New("torrent enclosures aren't supported")
+	}
+	b.once.Do(func() {
+		b.client, b.err = newTorrentClient(b.opts)
+	})
+	return b.client, b.err
+}
+
+// Stop the client if it was started.
+func (b *torrentBackend) Close() {
+	if b == nil || b.client == nil {
+		return
+	}
+	b.client.Close()
+}
+
+// Download the torrent enclosure.
+func (dl *Glsdl) downloadTorrent(source, dest string) (info remoteInfo, err error) {
+	client, err := dl.torrents.get()
+	if err != nil {
+		return info, err
+	}
+	n, err := client.Download(source, dest)
+	if err != nil {
+		_ = os.Remove(dest)
+		return info, err
+	}
+	dl.mux.Lock()
+	dl.statDl++
+	varDownloads.Add(1)
+	dl.mux.Unlock()
+	return remoteInfo{Size: n}, nil
+}
@@ -0,0 +1,10 @@
+//go:build !torrent
+
+package main
+
+import "errors"
+
+// BitTorrent client isn't included in the default build.
+func newTorrentClient(_ TorrentOptions) (torrentClient, error) {
+	return nil, errors.New("torrent enclosures aren't supported by this build, build it with -tags torrent")
+}
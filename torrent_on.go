@@ -0,0 +1,146 @@
+//go:build torrent
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Time without any downloaded data after which the torrent is given up.
+const torrentStallTimeout = 10 * time.Minute
+
+// Torrent client of anacrolix/torrent keeping the data in the temporary directory.
+type anacrolixClient struct {
+	opts    TorrentOptions
+	dir     string
+	client  *torrent.Client
+	seeding sync.WaitGroup
+	done    chan struct{}
+}
+
+func newTorrentClient(opts TorrentOptions) (torrentClient, error) {
+	dir, err := os.MkdirTemp("", "glsdl-torrent")
+	if err != nil {
+		return nil, err
+	}
+	conf := torrent.NewDefaultClientConfig()
+	conf.DataDir = dir
+	conf.Seed = opts.SeedRatio > 0
+	conf.NoUpload = opts.SeedRatio <= 0
+	client, err := torrent.NewClient(conf)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return &anacrolixClient{opts: opts, dir: dir, client: client, done: make(chan struct{})}, nil
+}
+
+func (c *anacrolixClient) Download(source, dest string) (int64, error) {
+	t, err := c.add(source)
+	if err != nil {
+		return 0, err
+	}
+	<-t.GotInfo()
+	var file *torrent.File
+	for _, f := range t.Files() {
+		if file == nil || f.Length() > file.Length() {
+			file = f
+		}
+	}
+	if file == nil {
+		t.Drop()
+		return 0, fmt.Errorf("%s: no files in torrent", source)
+	}
+	file.Download()
+	ticker := time.NewTicker(time.Second)
+	completed, progressed := int64(0), time.Now()
+	for file.BytesCompleted() < file.Length() {
+		<-ticker.C
+		if n := file.BytesCompleted(); n > completed {
+			completed, progressed = n, time.Now()
+		} else if time.Since(progressed) > torrentStallTimeout {
+			ticker.Stop()
+			t.Drop()
+			return 0, fmt.Errorf("%s: no peers sent data for %s", source, torrentStallTimeout)
+		}
+	}
+	ticker.Stop()
+	// The data is copied, the seeding needs it in place.
+	if err := copyFile(c.dir+ps+file.Path(), dest); err != nil {
+		t.Drop()
+		return 0, err
+	}
+	n := file.Length()
+	if c.opts.SeedRatio <= 0 {
+		t.Drop()
+		return n, nil
+	}
+	c.seeding.Add(1)
+	go c.seed(t, file.Length())
+	return n, nil
+}
+
+// Add the torrent by the magnet link or .torrent URL.
+func (c *anacrolixClient) add(source string) (*torrent.Torrent, error) {
+	if strings.HasPrefix(source, "magnet:") {
+		return c.client.AddMagnet(source)
+	}
+	resp, err := http.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &httpStatusError{URL: source, Code: resp.StatusCode, Status: resp.Status}
+	}
+	mi, err := metainfo.Load(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return c.client.AddTorrent(mi)
+}
+
+// Seed the torrent until the ratio is reached or the client is closed.
+func (c *anacrolixClient) seed(t *torrent.Torrent, size int64) {
+	defer c.seeding.Done()
+	defer t.Drop()
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		stats := t.Stats()
+		if float64(stats.BytesWrittenData.Int64()) >= c.opts.SeedRatio*float64(size) {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *anacrolixClient) Close() {
+	seeded := make(chan struct{})
+	go func() {
+		c.seeding.Wait()
+		close(seeded)
+	}()
+	select {
+	case <-seeded:
+	case <-time.After(c.opts.SeedTime):
+	}
+	close(c.done)
+	c.seeding.Wait()
+	c.client.Close()
+	_ = os.RemoveAll(c.dir)
+}
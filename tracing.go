@@ -0,0 +1,27 @@
+package main
+
+// Span of the traced step of the run.
+type span interface {
+	// Finish the span, failed if the error isn't nil.
+	End(err error)
+}
+
+// Tracer of the run steps: feed processing, episodes, downloads and tagging.
+type tracer interface {
+	// Start the span, child of the parent one if given. Attributes are key-value pairs.
+	start(parent span, name string, attrs ...string) span
+	// Flush the finished spans and stop the tracer.
+	Shutdown() error
+}
+
+// Tracer of the run, spans are dropped unless -otlp flag is set.
+var tracing tracer = noopTracer{}
+
+type noopTracer struct{}
+
+func (noopTracer) start(span, string, ...string) span { return noopSpan{} }
+func (noopTracer) Shutdown() error                    { return nil }
+
+type noopSpan struct{}
+
+func (noopSpan) End(error) {}
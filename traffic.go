@@ -0,0 +1,74 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Get the key of the month in the traffic accounting, like 2006-01.
+func trafficMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// Add the downloaded bytes to the traffic of the current month.
+func (s *State) AddTraffic(bytes int64) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.Traffic == nil {
+		s.Traffic = make(map[string]int64)
+	}
+	s.Traffic[trafficMonth(time.Now())] += bytes
+}
+
+// Get the bytes downloaded in the current month.
+func (s *State) MonthTraffic() int64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.Traffic[trafficMonth(time.Now())]
+}
+
+// Monthly traffic of all feeds with the soft quota: the started downloads are finished,
+// but no new ones are started after the quota is exceeded.
+type trafficMeter struct {
+	quota int64
+	mux   sync.Mutex
+	used  int64
+	// The warning is shown once per run.
+	warned bool
+}
+
+// Create the meter counting the traffic of the feeds recorded in their state DBs.
+func newTrafficMeter(quota int64, feeds []*FeedConfig) *trafficMeter {
+	m := &trafficMeter{quota: quota}
+	for _, feed := range feeds {
+		if s, err := LoadState(feed.dir + ps + StateFile); err == nil {
+			m.used += s.MonthTraffic()
+		}
+	}
+	return m
+}
+
+// Add the downloaded bytes.
+func (m *trafficMeter) add(bytes int64) {
+	if m == nil {
+		return
+	}
+	m.mux.Lock()
+	m.used += bytes
+	m.mux.Unlock()
+}
+
+// Check if the quota allows new downloads. The first refusal returns warn flag to show the warning.
+func (m *trafficMeter) allow() (ok, warn bool) {
+	if m == nil || m.quota <= 0 {
+		return true, false
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.used < m.quota {
+		return true, false
+	}
+	warn = !m.warned
+	m.warned = true
+	return false, warn
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Settings of the connections of all requests.
+type TransportOptions struct {
+	// Try HTTP/3 first.
+	HTTP3 bool
+	// DNS-over-HTTPS or DNS-over-TLS server, the system resolver is used by default.
+	DNS string
+	// Address family of the connections: tcp4 or tcp6, any by default.
+	Network string
+}
+
+// Set up the default transport used by all requests: HTTP/2 is negotiated over TLS,
+// HTTP/3 is tried first if enabled.
+func (o TransportOptions) setup() (http.RoundTripper, error) {
+	t := http.DefaultTransport.(*http.Transport)
+	// Custom TLS config disables HTTP/2 unless it's forced.
+	t.ForceAttemptHTTP2 = true
+	if len(o.DNS) > 0 || len(o.Network) > 0 {
+		// The same dialer settings as the default transport ones.
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if len(o.DNS) > 0 {
+			resolver, err := newResolver(o.DNS)
+			if err != nil {
+				return nil, err
+			}
+			dialer.Resolver = resolver
+		}
+		t.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if len(o.Network) > 0 && network == "tcp" {
+				network = o.Network
+			}
+			return dialer.DialContext(ctx, network, address)
+		}
+	}
+	if !o.HTTP3 {
+		return t, nil
+	}
+	h3t, err := newHTTP3Transport(t.TLSClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &fallbackTransport{primary: h3t, fallback: t, failed: make(map[string]bool)}, nil
+}
+
+// Transport trying the primary transport first and falling back to the other one for the hosts
+// the primary one fails with, like HTTP/3 on the networks blocking UDP.
+type fallbackTransport struct {
+	primary  http.RoundTripper
+	fallback http.RoundTripper
+	mux      sync.RWMutex
+	failed   map[string]bool
+}
+
+func (t *fallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mux.RLock()
+	failed := t.failed[req.URL.Host]
+	t.mux.RUnlock()
+	// Requests with the body can't be sent twice.
+	if failed || req.URL.Scheme != "https" || (req.Body != nil && req.Body != http.NoBody) {
+		return t.fallback.RoundTrip(req)
+	}
+	resp, err := t.primary.RoundTrip(req)
+	if err == nil || req.Context().Err() != nil {
+		return resp, err
+	}
+	t.mux.Lock()
+	t.failed[req.URL.Host] = true
+	t.mux.Unlock()
+	return t.fallback.RoundTrip(req)
+}
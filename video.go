@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Extensions of the video files by MIME type.
+var videoExts = map[string]string{
+	"video/mp4":       ".mp4",
+	"video/x-m4v":     ".m4v",
+	"video/quicktime": ".mov",
+}
+
+// Check if the enclosure is a video file.
+func isVideo(e *gofeed.Enclosure) bool {
+	return len(videoExt(e)) > 0
+}
+
+// Get the extension of the video enclosure, empty for other files.
+func videoExt(e *gofeed.Enclosure) string {
+	if e.Type == youtubeType {
+		return ""
+	}
+	if ext, ok := videoExts[strings.ToLower(e.Type)]; ok {
+		return ext
+	}
+	u := e.URL
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	ext := strings.ToLower(path.Ext(u))
+	for _, v := range videoExts {
+		if ext == v {
+			return ext
+		}
+	}
+	return ""
+}
+
+// Check if the file is a video one by the extension.
+func isVideoFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, v := range videoExts {
+		if ext == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Extensions of the audio files other than MP3 by MIME type, offered by podcast:alternateEnclosure.
+var audioExts = map[string]string{
+	"audio/opus":  ".opus",
+	"audio/ogg":   ".ogg",
+	"audio/mp4":   ".m4a",
+	"audio/x-m4a": ".m4a",
+	"audio/aac":   ".aac",
+	"audio/flac":  ".flac",
+}
+
+// Get the extension of the item file: the video extension, .m4a for the audio extracted from the video
+// (see audio_only config setting), the extension of the preferred audio format or .mp3.
+func (dl *Glsdl) itemExt(item *gofeed.Item) string {
+	m, ok := dl.itemMedia(item)
+	if !ok {
+		return ".mp3"
+	}
+	if ext, ok := audioExts[strings.ToLower(m.Type)]; ok {
+		return ext
+	}
+	ext := videoExt(m.Enclosure)
+	switch {
+	case len(ext) == 0:
+		return ".mp3"
+	case dl.conf.AudioOnly:
+		return ".m4a"
+	}
+	return ext
+}
+
+// Check if the file is tagged with ffmpeg instead of ID3: MP4 container (video or extracted audio)
+// or other audio formats.
+func isContainerFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".mp4", ".m4v", ".mov", ".m4a", ".opus", ".ogg", ".aac", ".flac":
+		return true
+	}
+	return false
+}
+
+// Extract the audio track of the video file without transcoding.
+func extractAudio(src, dest string) error {
+	cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error", "-i", src, "-vn", "-map_metadata", "0",
+		"-codec:a", "copy", "-f", "ipod", dest)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(dest)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// Write the metadata of the item with ffmpeg (MP4 metadata, Vorbis comments, etc.), the streams are copied as is.
+func (dl *Glsdl) writeContainerTags(filename string, item *gofeed.Item, title, number string) error {
+	tags := [][2]string{
+		{"title", title},
+		{"artist", dl.itemArtist(item)},
+		{"album_artist", dl.itemAlbumArtist()},
+		{"album", dl.itemAlbum(item)},
+		{"genre", dl.itemGenre(item)},
+		{"date", dl.itemYear(item)},
+		{"publisher", dl.itemPublisher(item)},
+		{"comment", item.Link},
+	}
+	if l, ok := dl.itemLicense(item); ok && dl.fundingTags {
+		tags = append(tags, [2]string{"copyright", l.Name})
+	}
+	if n := trackNumber(number); len(n) > 0 {
+		tags = append(tags, [2]string{"track", n})
+	}
+	args := []string{"-y", "-loglevel", "error", "-i", filename, "-map", "0", "-codec", "copy"}
+	if dl.strip {
+		args = append(args, "-map_metadata", "-1")
+	} else {
+		args = append(args, "-map_metadata", "0")
+	}
+	for _, t := range tags {
+		if len(t[1]) > 0 {
+			args = append(args, "-metadata", t[0]+"="+t[1])
+		}
+	}
+	// The extension of the temporary file keeps the container format.
+	tmp := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".tagging" + filepath.Ext(filename)
+	cmd := exec.Command("ffmpeg", append(args, tmp)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// MIME type of the YouTube video enclosures extracted by yt-dlp.
+const youtubeType = "video/x-youtube"
+
+// Channel ID in the channel page.
+var youtubeChannelID = regexp.MustCompile(`"(?:channelId|externalId)":"(UC[\w-]{22})"`)
+
+// Check if the URL points to YouTube.
+func isYouTubeURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(u.Hostname()), "www."), "m.")
+	return host == "youtube.com" || host == "youtu.be"
+}
+
+// Get the RSS feed URL of YouTube channel or playlist: channel/<id>, @handle, c/<name>, user/<name>
+// and playlist?list=<id> URLs are supported. Channel handles and names are resolved with the channel page.
+func youtubeFeedURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	const feeds = "https://www.youtube.com/feeds/videos.xml"
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case parts[0] == "feeds":
+		return rawURL, nil
+	case len(u.Query().Get("list")) > 0:
+		return feeds + "?playlist_id=" + url.QueryEscape(u.Query().Get("list")), nil
+	case parts[0] == "channel" && len(parts) > 1:
+		return feeds + "?channel_id=" + url.QueryEscape(parts[1]), nil
+	case strings.HasPrefix(parts[0], "@"), parts[0] == "c", parts[0] == "user":
+		id, err := youtubeResolveChannel(rawURL)
+		if err != nil {
+			return "", err
+		}
+		return feeds + "?channel_id=" + id, nil
+	}
+	return "", fmt.Errorf("%s: unsupported YouTube URL, channel or playlist expected", rawURL)
+}
+
+// Find the channel ID in the channel page.
+func youtubeResolveChannel(pageURL string) (string, error) {
+	resp, err := http.Get(pageURL)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "", &httpStatusError{URL: pageURL, Code: resp.StatusCode, Status: resp.Status}
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return "", err
+	}
+	m := youtubeChannelID.FindSubmatch(page)
+	if m == nil {
+		return "", fmt.Errorf("%s: channel ID not found", pageURL)
+	}
+	return string(m[1]), nil
+}
+
+// Get the enclosure of YouTube feed item, which links the video page instead of the media file.
+func youtubeEnclosure(item *gofeed.Item) (*gofeed.Enclosure, bool) {
+	if len(item.Link) == 0 || !isYouTubeURL(item.Link) {
+		return nil, false
+	}
+	return &gofeed.Enclosure{URL: item.Link, Type: youtubeType}, true
+}
+
+// Extract the audio of YouTube video to the MP3 file with yt-dlp.
+func (dl *Glsdl) downloadYouTube(videoURL, dest string) (info remoteInfo, err error) {
+	if _, err := exec.LookPath(*ytdlp); err != nil {
+		return info, fmt.Errorf("YouTube episodes require yt-dlp: %w", err)
+	}
+	// yt-dlp picks the extension itself.
+	base := strings.TrimSuffix(dest, filepath.Ext(dest))
+	cmd := exec.Command(*ytdlp, "--quiet", "--no-progress", "--no-playlist",
+		"--extract-audio", "--audio-format", "mp3", "--output", base+".%(ext)s", videoURL)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return info, fmt.Errorf("yt-dlp: %w", err)
+	}
+	if base+".mp3" != dest {
+		if err := os.Rename(base+".mp3", dest); err != nil {
+			return info, err
+		}
+	}
+	fi, err := os.Stat(dest)
+	if err != nil {
+		return info, errors.New("yt-dlp: no audio extracted")
+	}
+	dl.mux.Lock()
+	dl.statDl++
+	varDownloads.Add(1)
+	dl.mux.Unlock()
+	return remoteInfo{Size: fi.Size()}, nil
+}